@@ -1,5 +1,6 @@
 provider "oneshot" {
   # shell = "/bin/bash -c"
+  # log_encryption_recipient = "age1..."
 }
 
 resource "oneshot_run" "hello" {
@@ -0,0 +1,7 @@
+# Import a run by the run ID. The command is set from the configuration by the next apply without running it.
+import {
+  to = oneshot_run.hello
+  identity = {
+    id = "d6e1ff3c-5d0e-4d5e-9f4c-2c1c8f3b1a00"
+  }
+}
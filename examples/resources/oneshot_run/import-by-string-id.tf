@@ -0,0 +1,9 @@
+# Import a command that has already been executed without running it again.
+import {
+  to = oneshot_run.hello
+  id = jsonencode({
+    command      = "echo 'hello, oneshot'"
+    plan_command = "echo \"hello, oneshot (plan=$ONESHOT_PLAN)\""
+  })
+  # or: id = "command=echo 'hello%2C oneshot'"
+}
@@ -0,0 +1,2 @@
+# The ID is JSON or comma-separated key=value pairs of the attributes (values can be percent-encoded).
+terraform import oneshot_run.migrate 'command=./migrate.sh,triggers.version=1'
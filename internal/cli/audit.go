@@ -0,0 +1,41 @@
+package cli
+
+import (
+	"errors"
+	"flag"
+	"os"
+
+	"github.com/winebarrel/terraform-provider-oneshot/internal/util"
+)
+
+const (
+	EnvAuditLog = "ONESHOT_AUDIT_LOG"
+)
+
+func auditLogFlag(flags *flag.FlagSet) *string {
+	return flags.String("audit-log", "", "audit log file (default: $"+EnvAuditLog+")")
+}
+
+func openAuditLog(name string) (*os.File, error) {
+	if name == "" {
+		name = os.Getenv(EnvAuditLog)
+	}
+
+	if name == "" {
+		return nil, errors.New("audit log is required: specify -audit-log or $" + EnvAuditLog)
+	}
+
+	return os.Open(name)
+}
+
+func readAuditLog(name string) ([]util.AuditRecord, error) {
+	f, err := openAuditLog(name)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer f.Close()
+
+	return util.ReadAuditLog(f)
+}
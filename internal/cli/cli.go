@@ -0,0 +1,20 @@
+package cli
+
+import (
+	"io"
+)
+
+type Command func(args []string, stdout io.Writer) error
+
+var commands = map[string]Command{
+	"decrypt":      Decrypt,
+	"history":      History,
+	"logs":         Logs,
+	"replay":       Replay,
+	"verify-audit": VerifyAudit,
+}
+
+func Lookup(name string) (Command, bool) {
+	cmd, ok := commands[name]
+	return cmd, ok
+}
@@ -0,0 +1,87 @@
+package cli
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"filippo.io/age"
+	"github.com/winebarrel/terraform-provider-oneshot/internal/util"
+)
+
+const (
+	EnvLogIdentity = "ONESHOT_LOG_IDENTITY"
+)
+
+var errNoIdentity = errors.New("identity is required: specify -i or $" + EnvLogIdentity)
+
+func Decrypt(args []string, stdout io.Writer) error {
+	flags := flag.NewFlagSet("decrypt", flag.ContinueOnError)
+	identityFile := flags.String("i", "", "age identity file (default: $"+EnvLogIdentity+")")
+
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: terraform-provider-oneshot decrypt [-i IDENTITY_FILE] [LOG_FILE...]")
+		flags.PrintDefaults()
+	}
+
+	err := flags.Parse(args)
+
+	if err != nil {
+		return err
+	}
+
+	identities, err := loadIdentities(*identityFile)
+
+	if err != nil {
+		return err
+	}
+
+	if identities == nil {
+		return errNoIdentity
+	}
+
+	if flags.NArg() == 0 {
+		return util.Decrypt(stdout, os.Stdin, identities...)
+	}
+
+	for _, name := range flags.Args() {
+		f, err := os.Open(name)
+
+		if err != nil {
+			return err
+		}
+
+		err = util.Decrypt(stdout, f, identities...)
+		f.Close()
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// loadIdentities reads identities from the file or the environment variable.
+// It returns nil if neither is specified.
+func loadIdentities(identityFile string) ([]age.Identity, error) {
+	var identity string
+
+	if identityFile != "" {
+		b, err := os.ReadFile(identityFile)
+
+		if err != nil {
+			return nil, err
+		}
+
+		identity = string(b)
+	} else if v, ok := os.LookupEnv(EnvLogIdentity); ok {
+		identity = v
+	} else {
+		return nil, nil
+	}
+
+	return util.ParseIdentities(identity)
+}
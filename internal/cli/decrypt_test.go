@@ -0,0 +1,64 @@
+package cli_test
+
+import (
+	"bytes"
+	"os"
+	"testing"
+
+	"filippo.io/age"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/winebarrel/terraform-provider-oneshot/internal/cli"
+	"github.com/winebarrel/terraform-provider-oneshot/internal/util"
+)
+
+func TestDecrypt_OK(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	cwd, _ := os.Getwd()
+	os.Chdir(t.TempDir())
+	defer os.Chdir(cwd)
+
+	identity, _ := age.GenerateX25519Identity()
+	os.WriteFile("key.txt", []byte(identity.String()+"\n"), 0600)
+
+	cmd := util.NewCmd("/bin/bash -c", "stdout.log", "stderr.log")
+	cmd.Recipients = []age.Recipient{identity.Recipient()}
+	_, _, err := cmd.Run("echo stdout ; echo stderr 1>&2")
+	require.NoError(err)
+
+	var buf bytes.Buffer
+	err = cli.Decrypt([]string{"-i", "key.txt", "stdout.log", "stderr.log"}, &buf)
+	require.NoError(err)
+	assert.Equal("stdout\nstderr\n", buf.String())
+}
+
+func TestDecrypt_IdentityFromEnv(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	cwd, _ := os.Getwd()
+	os.Chdir(t.TempDir())
+	defer os.Chdir(cwd)
+
+	identity, _ := age.GenerateX25519Identity()
+	t.Setenv(cli.EnvLogIdentity, identity.String())
+
+	cmd := util.NewCmd("/bin/bash -c", "stdout.log", "/dev/null")
+	cmd.Recipients = []age.Recipient{identity.Recipient()}
+	_, _, err := cmd.Run("echo stdout")
+	require.NoError(err)
+
+	var buf bytes.Buffer
+	err = cli.Decrypt([]string{"stdout.log"}, &buf)
+	require.NoError(err)
+	assert.Equal("stdout\n", buf.String())
+}
+
+func TestDecrypt_WithoutIdentity(t *testing.T) {
+	assert := assert.New(t)
+	os.Unsetenv(cli.EnvLogIdentity)
+	err := cli.Decrypt([]string{"stdout.log"}, &bytes.Buffer{})
+	assert.ErrorContains(err, "identity is required")
+}
@@ -0,0 +1,135 @@
+package cli
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"time"
+
+	"github.com/winebarrel/terraform-provider-oneshot/internal/util"
+)
+
+func History(args []string, stdout io.Writer) error {
+	flags := flag.NewFlagSet("history", flag.ContinueOnError)
+	auditLog := auditLogFlag(flags)
+	since := flags.String("since", "", "show records started at or after the time (RFC3339 or duration like 24h)")
+	until := flags.String("until", "", "show records started before the time (RFC3339 or duration like 24h)")
+	status := flags.String("status", "", "show records with the status (succeeded or failed)")
+	phase := flags.String("phase", "", "show records with the phase (plan or apply)")
+	command := flags.String("command", "", "show records of the command")
+	runID := flags.String("run-id", "", "show records of the run ID")
+	jsonOutput := flags.Bool("json", false, "output records as JSON Lines")
+
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: terraform-provider-oneshot history [OPTIONS]")
+		flags.PrintDefaults()
+	}
+
+	err := flags.Parse(args)
+
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	var sinceTime, untilTime time.Time
+
+	if *since != "" {
+		sinceTime, err = parseTime(*since, now)
+
+		if err != nil {
+			return err
+		}
+	}
+
+	if *until != "" {
+		untilTime, err = parseTime(*until, now)
+
+		if err != nil {
+			return err
+		}
+	}
+
+	switch *status {
+	case "", "succeeded", "failed":
+	default:
+		return fmt.Errorf("invalid status: %s", *status)
+	}
+
+	recs, err := readAuditLog(*auditLog)
+
+	if err != nil {
+		return err
+	}
+
+	var filtered []util.AuditRecord
+
+	for _, rec := range recs {
+		if !sinceTime.IsZero() && rec.StartedAt.Before(sinceTime) ||
+			!untilTime.IsZero() && !rec.StartedAt.Before(untilTime) ||
+			*status == "succeeded" && !rec.Succeeded() ||
+			*status == "failed" && rec.Succeeded() ||
+			*phase != "" && rec.Phase != *phase ||
+			*command != "" && rec.CommandHash != util.CommandHash(*command) ||
+			*runID != "" && rec.RunID != *runID {
+			continue
+		}
+
+		filtered = append(filtered, rec)
+	}
+
+	if *jsonOutput {
+		enc := json.NewEncoder(stdout)
+
+		for _, rec := range filtered {
+			err := enc.Encode(rec)
+
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+
+	w := tabwriter.NewWriter(stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "STARTED_AT\tRUN_ID\tPHASE\tSTATUS\tEXIT_CODE\tDURATION\tWORKSPACE\tUSER\tCOMMAND_SHA256")
+
+	for _, rec := range filtered {
+		status := "succeeded"
+
+		if !rec.Succeeded() {
+			status = "failed"
+		}
+
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%d\t%s\t%s\t%s\t%.12s\n",
+			rec.StartedAt.Format(time.RFC3339),
+			rec.RunID,
+			rec.Phase,
+			status,
+			rec.ExitCode,
+			rec.FinishedAt.Sub(rec.StartedAt).Round(time.Millisecond),
+			rec.Workspace,
+			rec.User,
+			rec.CommandHash,
+		)
+	}
+
+	return w.Flush()
+}
+
+func parseTime(s string, now time.Time) (time.Time, error) {
+	if d, err := time.ParseDuration(s); err == nil {
+		return now.Add(-d), nil
+	}
+
+	t, err := time.Parse(time.RFC3339, s)
+
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid time: %s", s)
+	}
+
+	return t, nil
+}
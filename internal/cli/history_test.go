@@ -0,0 +1,79 @@
+package cli_test
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/winebarrel/terraform-provider-oneshot/internal/cli"
+	"github.com/winebarrel/terraform-provider-oneshot/internal/util"
+)
+
+func writeAuditLog(t *testing.T, recs ...util.AuditRecord) {
+	audit := util.NewAuditLog("audit.jsonl")
+
+	for _, rec := range recs {
+		err := audit.Append(&rec)
+		require.NoError(t, err)
+	}
+}
+
+func TestHistory_OK(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	cwd, _ := os.Getwd()
+	os.Chdir(t.TempDir())
+	defer os.Chdir(cwd)
+
+	now := time.Now().UTC().Truncate(time.Second)
+	writeAuditLog(t,
+		util.AuditRecord{RunID: "run-1", Phase: "apply", CommandHash: util.CommandHash("echo 1"), StartedAt: now.Add(-48 * time.Hour), FinishedAt: now.Add(-48 * time.Hour)},
+		util.AuditRecord{RunID: "run-2", Phase: "plan", CommandHash: util.CommandHash("echo 2"), StartedAt: now.Add(-time.Hour), FinishedAt: now.Add(-time.Hour)},
+		util.AuditRecord{RunID: "run-3", Phase: "apply", CommandHash: util.CommandHash("echo 2"), StartedAt: now, FinishedAt: now.Add(1500 * time.Millisecond), ExitCode: 1, Workspace: "default", User: "alice"},
+	)
+
+	var buf bytes.Buffer
+	err := cli.History([]string{"-audit-log", "audit.jsonl"}, &buf)
+	require.NoError(err)
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	require.Len(lines, 4)
+	assert.Regexp(`^STARTED_AT\s+RUN_ID\s+PHASE\s+STATUS\s+EXIT_CODE\s+DURATION\s+WORKSPACE\s+USER\s+COMMAND_SHA256$`, lines[0])
+	assert.Regexp(`^\S+\s+run-3\s+apply\s+failed\s+1\s+1\.5s\s+default\s+alice\s+[0-9a-f]{12}$`, lines[3])
+
+	buf.Reset()
+	err = cli.History([]string{"-audit-log", "audit.jsonl", "-since", "24h", "-command", "echo 2", "-status", "succeeded"}, &buf)
+	require.NoError(err)
+	lines = strings.Split(strings.TrimSpace(buf.String()), "\n")
+	require.Len(lines, 2)
+	assert.Contains(lines[1], "run-2")
+
+	buf.Reset()
+	t.Setenv(cli.EnvAuditLog, "audit.jsonl")
+	err = cli.History([]string{"-until", now.Add(-24 * time.Hour).Format(time.RFC3339), "-json"}, &buf)
+	require.NoError(err)
+	recs, _ := util.ReadAuditLog(&buf)
+	require.Len(recs, 1)
+	assert.Equal("run-1", recs[0].RunID)
+
+	buf.Reset()
+	err = cli.History([]string{"-phase", "apply", "-status", "failed"}, &buf)
+	require.NoError(err)
+	assert.Equal(2, strings.Count(buf.String(), "\n"))
+	assert.Contains(buf.String(), "run-3")
+}
+
+func TestHistory_Err(t *testing.T) {
+	assert := assert.New(t)
+	os.Unsetenv(cli.EnvAuditLog)
+	err := cli.History([]string{}, &bytes.Buffer{})
+	assert.ErrorContains(err, "audit log is required")
+	err = cli.History([]string{"-audit-log", "audit.jsonl", "-status", "unknown"}, &bytes.Buffer{})
+	assert.ErrorContains(err, "invalid status: unknown")
+	err = cli.History([]string{"-audit-log", "audit.jsonl", "-since", "yesterday"}, &bytes.Buffer{})
+	assert.ErrorContains(err, "invalid time: yesterday")
+}
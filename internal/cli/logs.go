@@ -0,0 +1,151 @@
+package cli
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/winebarrel/terraform-provider-oneshot/internal/util"
+)
+
+const (
+	ageHeader = "age-encryption.org/v1\n"
+)
+
+func Logs(args []string, stdout io.Writer) error {
+	flags := flag.NewFlagSet("logs", flag.ContinueOnError)
+	auditLog := auditLogFlag(flags)
+	phase := flags.String("phase", "", "show logs of the phase (plan or apply)")
+	stream := flags.String("stream", "", "show logs of the stream (stdout or stderr)")
+	lines := flags.Int("n", 0, "show the last n lines (0 means all)")
+	identityFile := flags.String("i", "", "age identity file to decrypt logs (default: $"+EnvLogIdentity+")")
+
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: terraform-provider-oneshot logs [OPTIONS] RUN_ID")
+		flags.PrintDefaults()
+	}
+
+	err := flags.Parse(args)
+
+	if err != nil {
+		return err
+	}
+
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return errors.New("run ID is required")
+	}
+
+	runID := flags.Arg(0)
+	recs, err := readAuditLog(*auditLog)
+
+	if err != nil {
+		return err
+	}
+
+	found := false
+
+	for i, rec := range recs {
+		if rec.RunID != runID || *phase != "" && rec.Phase != *phase {
+			continue
+		}
+
+		found = true
+
+		for _, log := range []struct {
+			stream string
+			path   string
+		}{
+			{"stdout", rec.StdoutLog},
+			{"stderr", rec.StderrLog},
+		} {
+			if log.path == "" || *stream != "" && log.stream != *stream {
+				continue
+			}
+
+			fmt.Fprintf(stdout, "==> %s (%s %s) <==\n", log.path, rec.Phase, log.stream)
+
+			if overwritten(recs[i+1:], log.path) {
+				fmt.Fprintf(os.Stderr, "WARNING: %s has been overwritten by a later run\n", log.path)
+			}
+
+			err := printLog(stdout, log.path, *lines, *identityFile)
+
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	if !found {
+		return fmt.Errorf("run not found: %s", runID)
+	}
+
+	return nil
+}
+
+func overwritten(laterRecs []util.AuditRecord, path string) bool {
+	for _, rec := range laterRecs {
+		if rec.StdoutLog == path || rec.StderrLog == path {
+			return true
+		}
+	}
+
+	return false
+}
+
+func printLog(w io.Writer, path string, lines int, identityFile string) error {
+	b, err := os.ReadFile(path)
+
+	if err != nil {
+		return err
+	}
+
+	if strings.HasPrefix(string(b), ageHeader) {
+		identities, err := loadIdentities(identityFile)
+
+		if err != nil {
+			return err
+		}
+
+		if identities == nil {
+			return errNoIdentity
+		}
+
+		var buf bytes.Buffer
+		err = util.Decrypt(&buf, bytes.NewReader(b), identities...)
+
+		if err != nil {
+			return err
+		}
+
+		b = buf.Bytes()
+	}
+
+	if lines <= 0 {
+		_, err = w.Write(b)
+		return err
+	}
+
+	var tail []string
+	scanner := bufio.NewScanner(bytes.NewReader(b))
+
+	for scanner.Scan() {
+		tail = append(tail, scanner.Text())
+
+		if len(tail) > lines {
+			tail = tail[1:]
+		}
+	}
+
+	for _, line := range tail {
+		fmt.Fprintln(w, line)
+	}
+
+	return scanner.Err()
+}
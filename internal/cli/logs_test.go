@@ -0,0 +1,80 @@
+package cli_test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"filippo.io/age"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/winebarrel/terraform-provider-oneshot/internal/cli"
+	"github.com/winebarrel/terraform-provider-oneshot/internal/util"
+)
+
+func TestLogs_OK(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	dir := t.TempDir()
+	cwd, _ := os.Getwd()
+	os.Chdir(dir)
+	defer os.Chdir(cwd)
+
+	os.WriteFile("stdout.log", []byte("1\n2\n3\n"), 0600)
+	os.WriteFile("stderr.log", []byte("err\n"), 0600)
+	stdoutLog := filepath.Join(dir, "stdout.log")
+	stderrLog := filepath.Join(dir, "stderr.log")
+	writeAuditLog(t, util.AuditRecord{RunID: "run-1", Phase: "apply", StdoutLog: stdoutLog, StderrLog: stderrLog})
+
+	var buf bytes.Buffer
+	err := cli.Logs([]string{"-audit-log", "audit.jsonl", "run-1"}, &buf)
+	require.NoError(err)
+	assert.Equal("==> "+stdoutLog+" (apply stdout) <==\n1\n2\n3\n==> "+stderrLog+" (apply stderr) <==\nerr\n", buf.String())
+
+	buf.Reset()
+	err = cli.Logs([]string{"-audit-log", "audit.jsonl", "-stream", "stdout", "-n", "2", "run-1"}, &buf)
+	require.NoError(err)
+	assert.Equal("==> "+stdoutLog+" (apply stdout) <==\n2\n3\n", buf.String())
+}
+
+func TestLogs_Encrypted(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	dir := t.TempDir()
+	cwd, _ := os.Getwd()
+	os.Chdir(dir)
+	defer os.Chdir(cwd)
+
+	identity, _ := age.GenerateX25519Identity()
+	cmd := util.NewCmd("/bin/bash -c", "stdout.log", "")
+	cmd.Recipients = []age.Recipient{identity.Recipient()}
+	_, _, err := cmd.Run("echo secret")
+	require.NoError(err)
+	stdoutLog := filepath.Join(dir, "stdout.log")
+	writeAuditLog(t, util.AuditRecord{RunID: "run-1", Phase: "apply", StdoutLog: stdoutLog})
+
+	os.Unsetenv(cli.EnvLogIdentity)
+	err = cli.Logs([]string{"-audit-log", "audit.jsonl", "run-1"}, &bytes.Buffer{})
+	assert.ErrorContains(err, "identity is required")
+
+	var buf bytes.Buffer
+	t.Setenv(cli.EnvLogIdentity, identity.String())
+	err = cli.Logs([]string{"-audit-log", "audit.jsonl", "run-1"}, &buf)
+	require.NoError(err)
+	assert.Equal("==> "+stdoutLog+" (apply stdout) <==\nsecret\n", buf.String())
+}
+
+func TestLogs_NotFound(t *testing.T) {
+	assert := assert.New(t)
+
+	cwd, _ := os.Getwd()
+	os.Chdir(t.TempDir())
+	defer os.Chdir(cwd)
+
+	writeAuditLog(t, util.AuditRecord{RunID: "run-1"})
+	err := cli.Logs([]string{"-audit-log", "audit.jsonl", "run-2"}, &bytes.Buffer{})
+	assert.ErrorContains(err, "run not found: run-2")
+}
@@ -0,0 +1,316 @@
+package cli
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclparse"
+	"github.com/winebarrel/terraform-provider-oneshot/internal/provider"
+	"github.com/winebarrel/terraform-provider-oneshot/internal/util"
+	"github.com/zclconf/go-cty/cty"
+)
+
+const redacted = "<redacted>"
+
+type invocation struct {
+	Shell      string
+	WorkingDir string
+	Command    string
+	Env        map[string]string
+}
+
+func Replay(args []string, stdout io.Writer) error {
+	flags := flag.NewFlagSet("replay", flag.ContinueOnError)
+	auditLog := auditLogFlag(flags)
+	stateFile := flags.String("state", "", "replay from the state file instead of the audit log")
+	address := flags.String("address", "", "resource address in the state file. e.g. oneshot_run.hello")
+	phase := flags.String("phase", provider.PhaseApply, "phase to replay (plan or apply)")
+	shell := flags.String("shell", "", "override the shell")
+	configDir := flags.String("config", ".", "directory of the Terraform configuration to read default_shell of the provider when replaying from the state file")
+	dryRun := flags.Bool("dry-run", false, "print the resolved invocation without executing it")
+	showEnv := flags.Bool("show-env", false, "print the values of the environment variables with -dry-run")
+
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: terraform-provider-oneshot replay [OPTIONS] RUN_ID")
+		fmt.Fprintln(flags.Output(), "       terraform-provider-oneshot replay [OPTIONS] -state FILE -address ADDRESS")
+		flags.PrintDefaults()
+	}
+
+	err := flags.Parse(args)
+
+	if err != nil {
+		return err
+	}
+
+	if *phase != provider.PhasePlan && *phase != provider.PhaseApply {
+		return fmt.Errorf("invalid phase: %s", *phase)
+	}
+
+	var inv *invocation
+
+	if *stateFile != "" {
+		if *address == "" {
+			return errors.New("-address is required with -state")
+		}
+
+		inv, err = invocationFromState(*stateFile, *address, *phase)
+	} else {
+		if flags.NArg() != 1 {
+			flags.Usage()
+			return errors.New("run ID is required")
+		}
+
+		inv, err = invocationFromAuditLog(*auditLog, flags.Arg(0), *phase)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if *shell != "" {
+		inv.Shell = *shell
+	} else if inv.Shell == "" {
+		inv.Shell, err = providerDefaultShell(*configDir)
+
+		if err != nil {
+			return err
+		}
+	}
+
+	envs := inv.envs()
+
+	if *dryRun {
+		fmt.Fprintf(stdout, "shell: %s\n", inv.Shell)
+		fmt.Fprintf(stdout, "working_dir: %s\n", inv.WorkingDir)
+
+		for _, env := range envs {
+			if !*showEnv {
+				// NOTE: The values may contain secrets
+				k, _, _ := strings.Cut(env, "=")
+				env = k + "=" + redacted
+			}
+
+			fmt.Fprintf(stdout, "env: %s\n", env)
+		}
+
+		fmt.Fprintf(stdout, "command: %s\n", inv.Command)
+
+		return nil
+	}
+
+	if inv.WorkingDir != "" {
+		cwd, _ := os.Getwd()
+		err := os.Chdir(inv.WorkingDir)
+
+		if err != nil {
+			return err
+		}
+
+		defer os.Chdir(cwd) //nolint:errcheck
+	}
+
+	cmd := util.NewCmd(inv.Shell, "", "")
+	cmd.StdoutTee = stdout
+	cmd.StderrTee = os.Stderr
+	_, _, err = cmd.Run(inv.Command, envs...)
+
+	if exitCode := util.ExitCode(err); exitCode > 0 {
+		return fmt.Errorf("replayed command failed: exit status %d", exitCode)
+	}
+
+	return err
+}
+
+// envs returns the recorded environment variables that differ from the current environment.
+func (inv *invocation) envs() []string {
+	var envs []string
+
+	for k, v := range inv.Env {
+		if cur, ok := os.LookupEnv(k); !ok || cur != v {
+			envs = append(envs, k+"="+v)
+		}
+	}
+
+	sort.Strings(envs)
+
+	return envs
+}
+
+func invocationFromAuditLog(auditLog string, runID string, phase string) (*invocation, error) {
+	recs, err := readAuditLog(auditLog)
+
+	if err != nil {
+		return nil, err
+	}
+
+	for i := len(recs) - 1; i >= 0; i-- {
+		rec := recs[i]
+
+		if rec.RunID != runID || rec.Phase != phase {
+			continue
+		}
+
+		if rec.Command == "" {
+			return nil, fmt.Errorf("command is not recorded: %s", runID)
+		}
+
+		inv := &invocation{
+			Shell:      rec.Shell,
+			WorkingDir: rec.WorkingDir,
+			Command:    rec.Command,
+			Env:        rec.Environment,
+		}
+
+		return inv, nil
+	}
+
+	return nil, fmt.Errorf("run not found: %s (%s)", runID, phase)
+}
+
+type tfState struct {
+	Resources []struct {
+		Module    string `json:"module"`
+		Mode      string `json:"mode"`
+		Type      string `json:"type"`
+		Name      string `json:"name"`
+		Instances []struct {
+			IndexKey   any            `json:"index_key"`
+			Attributes map[string]any `json:"attributes"`
+		} `json:"instances"`
+	} `json:"resources"`
+}
+
+func invocationFromState(stateFile string, address string, phase string) (*invocation, error) {
+	b, err := os.ReadFile(stateFile)
+
+	if err != nil {
+		return nil, err
+	}
+
+	var state tfState
+	err = json.Unmarshal(b, &state)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse state: %w", err)
+	}
+
+	for _, res := range state.Resources {
+		if res.Mode != "managed" || res.Type != "oneshot_run" {
+			continue
+		}
+
+		addr := res.Type + "." + res.Name
+
+		if res.Module != "" {
+			addr = res.Module + "." + addr
+		}
+
+		for _, inst := range res.Instances {
+			instAddr := addr
+
+			switch key := inst.IndexKey.(type) {
+			case float64:
+				instAddr += fmt.Sprintf("[%d]", int(key))
+			case string:
+				instAddr += fmt.Sprintf("[%q]", key)
+			}
+
+			if instAddr != address {
+				continue
+			}
+
+			attr := func(name string) string {
+				s, _ := inst.Attributes[name].(string)
+				return s
+			}
+
+			inv := &invocation{
+				Shell:      attr("shell"),
+				WorkingDir: attr("working_dir"),
+				Command:    attr("command"),
+				Env:        map[string]string{},
+			}
+
+			if env, ok := inst.Attributes["environment"].(map[string]any); ok {
+				for k, v := range env {
+					if s, ok := v.(string); ok {
+						inv.Env[k] = s
+					}
+				}
+			}
+
+			if phase == provider.PhasePlan {
+				inv.Command = attr("plan_command")
+				inv.Env["ONESHOT_PLAN"] = "1"
+			}
+
+			if inv.Command == "" {
+				return nil, fmt.Errorf("%s has no %s command", address, phase)
+			}
+
+			return inv, nil
+		}
+	}
+
+	return nil, fmt.Errorf("resource not found: %s", address)
+}
+
+// providerDefaultShell returns default_shell of the provider in the Terraform configuration,
+// because the state file does not contain the provider configuration.
+func providerDefaultShell(configDir string) (string, error) {
+	files, err := filepath.Glob(filepath.Join(configDir, "*.tf"))
+
+	if err != nil {
+		return "", err
+	}
+
+	parser := hclparse.NewParser()
+	providerSchema := &hcl.BodySchema{Blocks: []hcl.BlockHeaderSchema{{Type: "provider", LabelNames: []string{"name"}}}}
+	attrSchema := &hcl.BodySchema{Attributes: []hcl.AttributeSchema{{Name: "alias"}, {Name: "default_shell"}}}
+
+	for _, file := range files {
+		f, diags := parser.ParseHCLFile(file)
+
+		if diags.HasErrors() {
+			return "", diags
+		}
+
+		content, _, _ := f.Body.PartialContent(providerSchema)
+
+		for _, block := range content.Blocks {
+			if block.Labels[0] != "oneshot" {
+				continue
+			}
+
+			attrs, _, _ := block.Body.PartialContent(attrSchema)
+
+			if _, ok := attrs.Attributes["alias"]; ok {
+				continue
+			}
+
+			attr, ok := attrs.Attributes["default_shell"]
+
+			if !ok {
+				break
+			}
+
+			v, diags := attr.Expr.Value(nil)
+
+			if diags.HasErrors() || !v.Type().Equals(cty.String) || v.IsNull() {
+				return "", fmt.Errorf("default_shell of the provider is not a string literal, use -shell: %s", attr.Range)
+			}
+
+			return v.AsString(), nil
+		}
+	}
+
+	return provider.DefaultShell, nil
+}
@@ -0,0 +1,182 @@
+package cli_test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/winebarrel/terraform-provider-oneshot/internal/cli"
+	"github.com/winebarrel/terraform-provider-oneshot/internal/util"
+)
+
+func TestReplay_AuditLog(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	dir := t.TempDir()
+	cwd, _ := os.Getwd()
+	os.Chdir(dir)
+	defer os.Chdir(cwd)
+
+	os.Mkdir("workdir", 0700)
+	workdir := filepath.Join(dir, "workdir")
+	writeAuditLog(t,
+		util.AuditRecord{RunID: "run-1", Phase: "plan", Shell: "/bin/sh -c", WorkingDir: workdir, Command: "echo plan", Environment: map[string]string{"ONESHOT_PLAN": "1"}},
+		util.AuditRecord{RunID: "run-1", Phase: "apply", Shell: "/bin/sh -c", WorkingDir: workdir, Command: "echo $0 $FOO ; pwd", Environment: map[string]string{"FOO": "bar"}},
+	)
+
+	var buf bytes.Buffer
+	err := cli.Replay([]string{"-audit-log", "audit.jsonl", "-dry-run", "run-1"}, &buf)
+	require.NoError(err)
+	assert.Equal("shell: /bin/sh -c\nworking_dir: "+workdir+"\nenv: FOO=<redacted>\ncommand: echo $0 $FOO ; pwd\n", buf.String())
+
+	buf.Reset()
+	err = cli.Replay([]string{"-audit-log", "audit.jsonl", "-dry-run", "-show-env", "run-1"}, &buf)
+	require.NoError(err)
+	assert.Equal("shell: /bin/sh -c\nworking_dir: "+workdir+"\nenv: FOO=bar\ncommand: echo $0 $FOO ; pwd\n", buf.String())
+
+	buf.Reset()
+	err = cli.Replay([]string{"-audit-log", "audit.jsonl", "run-1"}, &buf)
+	require.NoError(err)
+	assert.Equal("/bin/sh bar\n"+workdir+"\n", buf.String())
+
+	buf.Reset()
+	err = cli.Replay([]string{"-audit-log", "audit.jsonl", "-phase", "plan", "-shell", "/bin/bash -c", "-dry-run", "run-1"}, &buf)
+	require.NoError(err)
+	assert.Equal("shell: /bin/bash -c\nworking_dir: "+workdir+"\nenv: ONESHOT_PLAN=<redacted>\ncommand: echo plan\n", buf.String())
+}
+
+func TestReplay_State(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	cwd, _ := os.Getwd()
+	os.Chdir(t.TempDir())
+	defer os.Chdir(cwd)
+
+	os.WriteFile("terraform.tfstate", []byte(`{
+		"version": 4,
+		"resources": [
+			{
+				"mode": "managed",
+				"type": "oneshot_run",
+				"name": "hello",
+				"instances": [
+					{"attributes": {"command": "echo hello $NAME", "plan_command": "echo plan=$ONESHOT_PLAN", "shell": null, "working_dir": null, "environment": {"NAME": "world"}}}
+				]
+			},
+			{
+				"module": "module.foo",
+				"mode": "managed",
+				"type": "oneshot_run",
+				"name": "each",
+				"instances": [
+					{"index_key": "a", "attributes": {"command": "echo a", "shell": "/bin/sh -c"}},
+					{"index_key": "b", "attributes": {"command": "echo b", "shell": "/bin/sh -c"}}
+				]
+			}
+		]
+	}`), 0600)
+
+	var buf bytes.Buffer
+	err := cli.Replay([]string{"-state", "terraform.tfstate", "-address", "oneshot_run.hello"}, &buf)
+	require.NoError(err)
+	assert.Equal("hello world\n", buf.String())
+
+	buf.Reset()
+	os.Unsetenv("ONESHOT_PLAN")
+	err = cli.Replay([]string{"-state", "terraform.tfstate", "-address", "oneshot_run.hello", "-phase", "plan"}, &buf)
+	require.NoError(err)
+	assert.Equal("plan=1\n", buf.String())
+
+	buf.Reset()
+	err = cli.Replay([]string{"-state", "terraform.tfstate", "-address", `module.foo.oneshot_run.each["b"]`, "-dry-run"}, &buf)
+	require.NoError(err)
+	assert.Equal("shell: /bin/sh -c\nworking_dir: \ncommand: echo b\n", buf.String())
+
+	buf.Reset()
+	err = cli.Replay([]string{"-state", "terraform.tfstate", "-address", "oneshot_run.hello", "-dry-run"}, &buf)
+	require.NoError(err)
+	assert.Equal("shell: /bin/bash -c\nworking_dir: \nenv: NAME=<redacted>\ncommand: echo hello $NAME\n", buf.String())
+
+	err = cli.Replay([]string{"-state", "terraform.tfstate", "-address", "oneshot_run.none"}, &buf)
+	assert.ErrorContains(err, "resource not found: oneshot_run.none")
+}
+
+func TestReplay_StateProviderDefaultShell(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	cwd, _ := os.Getwd()
+	os.Chdir(t.TempDir())
+	defer os.Chdir(cwd)
+
+	os.WriteFile("terraform.tfstate", []byte(`{
+		"version": 4,
+		"resources": [
+			{
+				"mode": "managed",
+				"type": "oneshot_run",
+				"name": "hello",
+				"instances": [
+					{"attributes": {"command": "echo hello", "shell": null}}
+				]
+			}
+		]
+	}`), 0600)
+
+	os.Mkdir("config", 0700)
+	os.WriteFile("config/provider.tf", []byte(`
+		provider "oneshot" {
+			alias         = "zsh"
+			default_shell = "/bin/zsh -c"
+		}
+
+		provider "oneshot" {
+			default_shell = "/bin/sh -c"
+
+			notification {
+				url = "https://example.com"
+			}
+		}
+	`), 0600)
+
+	var buf bytes.Buffer
+	err := cli.Replay([]string{"-state", "terraform.tfstate", "-address", "oneshot_run.hello", "-config", "config", "-dry-run"}, &buf)
+	require.NoError(err)
+	assert.Equal("shell: /bin/sh -c\nworking_dir: \ncommand: echo hello\n", buf.String())
+
+	buf.Reset()
+	err = cli.Replay([]string{"-state", "terraform.tfstate", "-address", "oneshot_run.hello", "-config", "config", "-shell", "/bin/dash -c", "-dry-run"}, &buf)
+	require.NoError(err)
+	assert.Equal("shell: /bin/dash -c\nworking_dir: \ncommand: echo hello\n", buf.String())
+
+	os.WriteFile("config/provider.tf", []byte(`
+		provider "oneshot" {
+			default_shell = var.shell
+		}
+	`), 0600)
+
+	err = cli.Replay([]string{"-state", "terraform.tfstate", "-address", "oneshot_run.hello", "-config", "config", "-dry-run"}, &buf)
+	assert.ErrorContains(err, "default_shell of the provider is not a string literal, use -shell")
+}
+
+func TestReplay_Err(t *testing.T) {
+	assert := assert.New(t)
+
+	cwd, _ := os.Getwd()
+	os.Chdir(t.TempDir())
+	defer os.Chdir(cwd)
+
+	writeAuditLog(t, util.AuditRecord{RunID: "run-1", Phase: "apply", Shell: "/bin/bash -c", Command: "exit 3"})
+
+	err := cli.Replay([]string{"-audit-log", "audit.jsonl", "run-1"}, &bytes.Buffer{})
+	assert.ErrorContains(err, "replayed command failed: exit status 3")
+	err = cli.Replay([]string{"-audit-log", "audit.jsonl", "run-2"}, &bytes.Buffer{})
+	assert.ErrorContains(err, "run not found: run-2 (apply)")
+	err = cli.Replay([]string{"-audit-log", "audit.jsonl", "-phase", "destroy", "run-1"}, &bytes.Buffer{})
+	assert.ErrorContains(err, "invalid phase: destroy")
+}
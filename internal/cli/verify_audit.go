@@ -0,0 +1,42 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"io"
+
+	"github.com/winebarrel/terraform-provider-oneshot/internal/util"
+)
+
+func VerifyAudit(args []string, stdout io.Writer) error {
+	flags := flag.NewFlagSet("verify-audit", flag.ContinueOnError)
+	auditLog := auditLogFlag(flags)
+
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: terraform-provider-oneshot verify-audit [-audit-log FILE]")
+		flags.PrintDefaults()
+	}
+
+	err := flags.Parse(args)
+
+	if err != nil {
+		return err
+	}
+
+	f, err := openAuditLog(*auditLog)
+
+	if err != nil {
+		return err
+	}
+
+	defer f.Close()
+	n, err := util.VerifyAuditLog(f)
+
+	if err != nil {
+		return fmt.Errorf("audit log is broken after %d verified records: %w", n, err)
+	}
+
+	fmt.Fprintf(stdout, "OK: %d records verified\n", n)
+
+	return nil
+}
@@ -0,0 +1,44 @@
+package cli_test
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/winebarrel/terraform-provider-oneshot/internal/cli"
+	"github.com/winebarrel/terraform-provider-oneshot/internal/util"
+)
+
+func TestVerifyAudit_OK(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	cwd, _ := os.Getwd()
+	os.Chdir(t.TempDir())
+	defer os.Chdir(cwd)
+
+	writeAuditLog(t, util.AuditRecord{RunID: "run-1"}, util.AuditRecord{RunID: "run-2"})
+
+	var buf bytes.Buffer
+	err := cli.VerifyAudit([]string{"-audit-log", "audit.jsonl"}, &buf)
+	require.NoError(err)
+	assert.Equal("OK: 2 records verified\n", buf.String())
+}
+
+func TestVerifyAudit_Tampered(t *testing.T) {
+	assert := assert.New(t)
+
+	cwd, _ := os.Getwd()
+	os.Chdir(t.TempDir())
+	defer os.Chdir(cwd)
+
+	writeAuditLog(t, util.AuditRecord{RunID: "run-1"}, util.AuditRecord{RunID: "run-2"})
+	b, _ := os.ReadFile("audit.jsonl")
+	os.WriteFile("audit.jsonl", []byte(strings.Replace(string(b), "run-2", "run-x", 1)), 0600)
+
+	err := cli.VerifyAudit([]string{"-audit-log", "audit.jsonl"}, &bytes.Buffer{})
+	assert.ErrorContains(err, "audit log is broken after 1 verified records: record 2: hash mismatch")
+}
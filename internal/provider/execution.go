@@ -0,0 +1,307 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-framework/attr"
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/winebarrel/terraform-provider-oneshot/internal/util"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+// Execution is the result of a plan or apply command execution.
+type Execution struct {
+	RunID       string
+	Label       string
+	Phase       string
+	Command     string
+	Environ     []string
+	ExtraEnvs   []string
+	Shell       string
+	WorkingDir  string
+	Workspace   string
+	StartedAt   time.Time
+	FinishedAt  time.Time
+	ExitCode    int
+	Succeeded   bool
+	StdoutBytes int
+	StderrBytes int
+	StdoutLog   string
+	StderrLog   string
+	Stdout      string
+	Stderr      string
+	Fingerprint string
+	Usage       *util.ResourceUsage
+	SyslogErr   error
+	VerifyErr   error
+}
+
+// ExitCodeVerificationFailed is the exit code of the run that exited with zero but failed the verification.
+const ExitCodeVerificationFailed = 1
+
+// FailVerification marks the execution failed despite the zero exit status,
+// so that the records, the notification, the state and the hooks see the failure.
+func (exec *Execution) FailVerification(err error) {
+	exec.ExitCode = ExitCodeVerificationFailed
+	exec.Succeeded = false
+	exec.VerifyErr = err
+}
+
+// errorMessage returns the reason why the execution failed other than the exit status.
+func (exec *Execution) errorMessage() string {
+	if exec.VerifyErr == nil {
+		return ""
+	}
+
+	return exec.VerifyErr.Error()
+}
+
+var executionAttrTypes = map[string]attr.Type{
+	"duration_seconds":   types.Float64Type,
+	"user_cpu_seconds":   types.Float64Type,
+	"system_cpu_seconds": types.Float64Type,
+	"max_rss_bytes":      types.Int64Type,
+	"signal":             types.StringType,
+}
+
+// Record writes the execution to the audit log and the metrics file.
+// It also reports the failure of syslog during the execution.
+// It does nothing if the command was not executed.
+func (exec *Execution) Record(providerData OneshotProviderModel) diag.Diagnostics {
+	var diags diag.Diagnostics
+
+	if exec == nil {
+		return diags
+	}
+
+	if exec.SyslogErr != nil {
+		diags.AddWarning("Syslog Error", fmt.Sprintf("Unable to connect to syslog, got error: %s", exec.SyslogErr))
+	}
+
+	if providerData.AuditLogger != nil {
+		usage := exec.usage()
+		err := providerData.AuditLogger.Append(&util.AuditRecord{
+			RunID:       exec.RunID,
+			Phase:       exec.Phase,
+			CommandHash: util.CommandHash(exec.Command),
+			Command:     auditCommand(providerData.AuditLogger, exec.Command),
+			Shell:       exec.Shell,
+			WorkingDir:  exec.WorkingDir,
+			User:        currentUser(),
+			Host:        hostname(),
+			Workspace:   exec.Workspace,
+			StartedAt:   exec.StartedAt,
+			FinishedAt:  exec.FinishedAt,
+			ExitCode:    exec.ExitCode,
+			Outcome:     util.Outcome(exec.Succeeded),
+			StdoutBytes: exec.StdoutBytes,
+			StderrBytes: exec.StderrBytes,
+			UserCPU:     usage.UserTime.Seconds(),
+			SystemCPU:   usage.SystemTime.Seconds(),
+			MaxRSS:      usage.MaxRSS,
+			Signal:      usage.Signal,
+			StdoutLog:   exec.StdoutLog,
+			StderrLog:   exec.StderrLog,
+			Environment: util.AuditEnv(exec.ExtraEnvs, append(os.Environ(), exec.Environ...), providerData.AuditLogger.Environment),
+			Error:       exec.errorMessage(),
+		})
+
+		if err != nil {
+			diags.AddWarning("Audit Log Error", fmt.Sprintf("Unable to write audit log, got error: %s", err))
+		}
+	}
+
+	if providerData.Metrics != nil {
+		err := providerData.Metrics.Record(util.MetricsSample{
+			Label:      exec.Label,
+			Phase:      exec.Phase,
+			Succeeded:  exec.Succeeded,
+			Duration:   exec.FinishedAt.Sub(exec.StartedAt),
+			FinishedAt: exec.FinishedAt,
+		})
+
+		if err != nil {
+			diags.AddWarning("Metrics Error", fmt.Sprintf("Unable to write metrics, got error: %s", err))
+		}
+	}
+
+	return diags
+}
+
+// auditCommand returns the command to record in the audit log, which is empty unless audit_log_command is enabled.
+func auditCommand(auditLog *util.AuditLog, command string) string {
+	if !auditLog.RecordCommand {
+		return ""
+	}
+
+	return command
+}
+
+// webhookCommand returns the command to send to the webhook, which is empty unless include_command is enabled.
+func webhookCommand(webhook *util.Webhook, command string) string {
+	if !webhook.IncludeCommand {
+		return ""
+	}
+
+	return command
+}
+
+// syslogCommand returns the message of the start event, which is the command only if include_command is enabled.
+func syslogCommand(syslog *util.Syslog, command string) string {
+	if !syslog.IncludeCommand {
+		return "started"
+	}
+
+	return command
+}
+
+func (exec *Execution) usage() util.ResourceUsage {
+	if exec.Usage == nil {
+		return util.ResourceUsage{}
+	}
+
+	return *exec.Usage
+}
+
+// Object returns the value of the execution attribute.
+func (exec *Execution) Object() types.Object {
+	if exec == nil {
+		return types.ObjectNull(executionAttrTypes)
+	}
+
+	usage := exec.usage()
+	signal := types.StringNull()
+
+	if usage.Signal != "" {
+		signal = types.StringValue(usage.Signal)
+	}
+
+	return types.ObjectValueMust(executionAttrTypes, map[string]attr.Value{
+		"duration_seconds":   types.Float64Value(exec.FinishedAt.Sub(exec.StartedAt).Seconds()),
+		"user_cpu_seconds":   types.Float64Value(usage.UserTime.Seconds()),
+		"system_cpu_seconds": types.Float64Value(usage.SystemTime.Seconds()),
+		"max_rss_bytes":      types.Int64Value(usage.MaxRSS),
+		"signal":             signal,
+	})
+}
+
+// Notify posts the result of the execution to the webhook.
+func (exec *Execution) Notify(ctx context.Context, providerData OneshotProviderModel) diag.Diagnostics {
+	var diags diag.Diagnostics
+
+	if exec == nil || providerData.Webhook == nil {
+		return diags
+	}
+
+	event := util.EventSuccess
+
+	if !exec.Succeeded {
+		event = util.EventFailure
+	}
+
+	err := providerData.Webhook.Notify(ctx, &util.WebhookPayload{
+		Event:       event,
+		RunID:       exec.RunID,
+		Label:       exec.Label,
+		Phase:       exec.Phase,
+		Command:     webhookCommand(providerData.Webhook, exec.Command),
+		CommandHash: util.CommandHash(exec.Command),
+		ExitCode:    exec.ExitCode,
+		StartedAt:   exec.StartedAt,
+		FinishedAt:  exec.FinishedAt,
+		Duration:    exec.FinishedAt.Sub(exec.StartedAt).Seconds(),
+		User:        currentUser(),
+		Host:        hostname(),
+		Workspace:   exec.Workspace,
+		Error:       exec.errorMessage(),
+	})
+
+	if err != nil {
+		diags.AddWarning("Notification Error", fmt.Sprintf("Unable to send notification, got error: %s", err))
+	}
+
+	return diags
+}
+
+// startSpan starts a span as a child of TRACEPARENT.
+// It returns a no-op span if tracing is not configured.
+func (providerData OneshotProviderModel) startSpan(ctx context.Context, name string) (context.Context, trace.Span) {
+	if providerData.Tracer == nil {
+		return ctx, noop.Span{}
+	}
+
+	return providerData.Tracer.Start(util.ExtractTraceContext(ctx), name)
+}
+
+func (exec *Execution) setSpanAttributes(span trace.Span) {
+	span.SetAttributes(
+		attribute.String("oneshot.run_id", exec.RunID),
+		attribute.String("oneshot.phase", exec.Phase),
+		attribute.String("oneshot.command.sha256", util.CommandHash(exec.Command)),
+		attribute.String("oneshot.shell", exec.Shell),
+		attribute.Int("oneshot.exit_code", exec.ExitCode),
+		attribute.Int64("oneshot.duration_ms", exec.FinishedAt.Sub(exec.StartedAt).Milliseconds()),
+		attribute.Int("oneshot.stdout.bytes", exec.StdoutBytes),
+		attribute.Int("oneshot.stderr.bytes", exec.StderrBytes),
+	)
+
+	if !exec.Succeeded {
+		span.SetStatus(codes.Error, fmt.Sprintf("exit code %d", exec.ExitCode))
+	}
+}
+
+func absPath(name string) string {
+	if name == "" {
+		return ""
+	}
+
+	if abs, err := filepath.Abs(name); err == nil {
+		return abs
+	}
+
+	return name
+}
+
+func currentUser() string {
+	if u, err := user.Current(); err == nil {
+		return u.Username
+	}
+
+	return os.Getenv("USER")
+}
+
+func hostname() string {
+	h, _ := os.Hostname()
+	return h
+}
+
+// terraformWorkspace returns the selected workspace in the same way as Terraform.
+func terraformWorkspace() string {
+	if ws := os.Getenv("TF_WORKSPACE"); ws != "" {
+		return ws
+	}
+
+	dataDir := os.Getenv("TF_DATA_DIR")
+
+	if dataDir == "" {
+		dataDir = ".terraform"
+	}
+
+	if b, err := os.ReadFile(filepath.Join(dataDir, "environment")); err == nil {
+		if ws := strings.TrimSpace(string(b)); ws != "" {
+			return ws
+		}
+	}
+
+	return "default"
+}
@@ -2,16 +2,39 @@ package provider
 
 import (
 	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
 
+	"filippo.io/age"
+	"github.com/hashicorp/terraform-plugin-framework-validators/int64validator"
+	"github.com/hashicorp/terraform-plugin-framework-validators/listvalidator"
+	"github.com/hashicorp/terraform-plugin-framework-validators/stringvalidator"
 	"github.com/hashicorp/terraform-plugin-framework/datasource"
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/path"
 	"github.com/hashicorp/terraform-plugin-framework/provider"
 	"github.com/hashicorp/terraform-plugin-framework/provider/schema"
 	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/schema/validator"
 	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/winebarrel/terraform-provider-oneshot/internal/util"
+	"go.opentelemetry.io/otel/trace"
 )
 
 const (
-	DefaultShell = "/bin/bash -c"
+	DefaultShell               = "/bin/bash -c"
+	DefaultLogSinkRegion       = "us-east-1"
+	DefaultSyslogNetwork       = "unixgram"
+	DefaultSyslogAddress       = "/dev/log"
+	DefaultSyslogTag           = "terraform-provider-oneshot"
+	DefaultSyslogFacility      = "user"
+	DefaultTracingServiceName  = "terraform-provider-oneshot"
+	DefaultNotificationRetries = 3
+	DefaultNotificationTimeout = "10s"
+	EnvLogEncryptionRecipient  = "ONESHOT_LOG_ENCRYPTION_RECIPIENT"
 )
 
 var _ provider.Provider = &OneshotProvider{}
@@ -21,7 +44,59 @@ type OneshotProvider struct {
 }
 
 type OneshotProviderModel struct {
-	DefaultShell types.String `tfsdk:"default_shell"`
+	DefaultShell           types.String       `tfsdk:"default_shell"`
+	LogEncryptionRecipient types.String       `tfsdk:"log_encryption_recipient"`
+	LogSink                *LogSinkModel      `tfsdk:"log_sink"`
+	Syslog                 *SyslogModel       `tfsdk:"syslog"`
+	AuditLog               types.String       `tfsdk:"audit_log"`
+	AuditLogCommand        types.Bool         `tfsdk:"audit_log_command"`
+	AuditLogEnvironment    types.List         `tfsdk:"audit_log_environment"`
+	Tracing                *TracingModel      `tfsdk:"tracing"`
+	MetricsFile            types.String       `tfsdk:"metrics_file"`
+	Notification           *NotificationModel `tfsdk:"notification"`
+	BeforeEach             types.String       `tfsdk:"before_each"`
+	AfterEach              types.String       `tfsdk:"after_each"`
+	Recipients             []age.Recipient    `tfsdk:"-"`
+	Uploader               *util.S3Uploader   `tfsdk:"-"`
+	Syslogger              *util.Syslog       `tfsdk:"-"`
+	AuditLogger            *util.AuditLog     `tfsdk:"-"`
+	Tracer                 trace.Tracer       `tfsdk:"-"`
+	Metrics                *util.MetricsFile  `tfsdk:"-"`
+	Webhook                *util.Webhook      `tfsdk:"-"`
+}
+
+type LogSinkModel struct {
+	Endpoint  types.String `tfsdk:"endpoint"`
+	Region    types.String `tfsdk:"region"`
+	Bucket    types.String `tfsdk:"bucket"`
+	Prefix    types.String `tfsdk:"prefix"`
+	AccessKey types.String `tfsdk:"access_key"`
+	SecretKey types.String `tfsdk:"secret_key"`
+}
+
+type SyslogModel struct {
+	Network        types.String `tfsdk:"network"`
+	Address        types.String `tfsdk:"address"`
+	Tag            types.String `tfsdk:"tag"`
+	Facility       types.String `tfsdk:"facility"`
+	IncludeCommand types.Bool   `tfsdk:"include_command"`
+}
+
+type TracingModel struct {
+	ServiceName types.String `tfsdk:"service_name"`
+	Endpoint    types.String `tfsdk:"endpoint"`
+	File        types.String `tfsdk:"file"`
+}
+
+type NotificationModel struct {
+	URL             types.String `tfsdk:"url"`
+	Headers         types.Map    `tfsdk:"headers"`
+	Events          types.List   `tfsdk:"events"`
+	PayloadTemplate types.String `tfsdk:"payload_template"`
+	Secret          types.String `tfsdk:"secret"`
+	Retries         types.Int64  `tfsdk:"retries"`
+	Timeout         types.String `tfsdk:"timeout"`
+	IncludeCommand  types.Bool   `tfsdk:"include_command"`
 }
 
 func (p *OneshotProvider) Metadata(ctx context.Context, req provider.MetadataRequest, resp *provider.MetadataResponse) {
@@ -36,6 +111,169 @@ func (p *OneshotProvider) Schema(ctx context.Context, req provider.SchemaRequest
 				MarkdownDescription: "Default shell to execute the command. (default: " + DefaultShell + ")",
 				Optional:            true,
 			},
+			"log_encryption_recipient": schema.StringAttribute{
+				MarkdownDescription: "[age](https://age-encryption.org) recipients used to encrypt the log files, one per line. " +
+					"Can also be set with the `" + EnvLogEncryptionRecipient + "` environment variable.",
+				Optional: true,
+			},
+			"audit_log": schema.StringAttribute{
+				MarkdownDescription: "JSON Lines file to append an audit record of each plan and apply command execution. " +
+					"Each record is hash-chained to the previous one for tamper evidence.",
+				Optional: true,
+			},
+			"audit_log_command": schema.BoolAttribute{
+				MarkdownDescription: "Record the commands in plain text in the audit log. Otherwise, only the SHA-256 of the commands is recorded. (default: false)",
+				Optional:            true,
+			},
+			"audit_log_environment": schema.ListAttribute{
+				MarkdownDescription: "Names of the environment variables recorded in the audit log, in addition to the variables passed by oneshot such as `ONESHOT_RUN_ID`.",
+				ElementType:         types.StringType,
+				Optional:            true,
+			},
+			"metrics_file": schema.StringAttribute{
+				MarkdownDescription: "File to write the metrics of the command executions in the Prometheus text format, " +
+					"for the node exporter textfile collector. e.g. `/var/lib/node_exporter/textfile_collector/oneshot.prom`",
+				Optional: true,
+			},
+			"before_each": schema.StringAttribute{
+				MarkdownDescription: "Command executed before `pre_command` and `command` of each run.",
+				Optional:            true,
+			},
+			"after_each": schema.StringAttribute{
+				MarkdownDescription: "Command executed after `command` and `post_command` of each run, even if they or the pre hooks failed. " +
+					"The exit code of the command is passed as `ONESHOT_EXIT_CODE` if it was executed.",
+				Optional: true,
+			},
+		},
+		Blocks: map[string]schema.Block{
+			"log_sink": schema.SingleNestedBlock{
+				MarkdownDescription: "S3-compatible bucket to upload the log files of each run.",
+				Attributes: map[string]schema.Attribute{
+					"endpoint": schema.StringAttribute{
+						MarkdownDescription: "Endpoint URL of the S3-compatible storage. e.g. `https://s3.us-east-1.amazonaws.com`",
+						Optional:            true,
+					},
+					"region": schema.StringAttribute{
+						MarkdownDescription: "Region of the bucket. (default: `$AWS_REGION` or " + DefaultLogSinkRegion + ")",
+						Optional:            true,
+					},
+					"bucket": schema.StringAttribute{
+						MarkdownDescription: "Bucket name.",
+						Optional:            true,
+					},
+					"prefix": schema.StringAttribute{
+						MarkdownDescription: "Object key prefix. Logs are uploaded to `<prefix><run id>/stdout.log` and `<prefix><run id>/stderr.log`.",
+						Optional:            true,
+					},
+					"access_key": schema.StringAttribute{
+						MarkdownDescription: "Access key. (default: `$AWS_ACCESS_KEY_ID`)",
+						Optional:            true,
+					},
+					"secret_key": schema.StringAttribute{
+						MarkdownDescription: "Secret key. (default: `$AWS_SECRET_ACCESS_KEY`)",
+						Optional:            true,
+						Sensitive:           true,
+					},
+				},
+			},
+			"syslog": schema.SingleNestedBlock{
+				MarkdownDescription: "Syslog endpoint to forward each output line and the start/finish events of the commands in RFC 5424 format.",
+				Attributes: map[string]schema.Attribute{
+					"network": schema.StringAttribute{
+						MarkdownDescription: "Network of the syslog endpoint. `udp`, `tcp`, `unix` or `unixgram`. (default: " + DefaultSyslogNetwork + ")",
+						Optional:            true,
+						Validators: []validator.String{
+							stringvalidator.OneOf("udp", "tcp", "unix", "unixgram"),
+						},
+					},
+					"address": schema.StringAttribute{
+						MarkdownDescription: "Address of the syslog endpoint. e.g. `localhost:514` (default: " + DefaultSyslogAddress + ")",
+						Optional:            true,
+					},
+					"tag": schema.StringAttribute{
+						MarkdownDescription: "APP-NAME of the messages. (default: " + DefaultSyslogTag + ")",
+						Optional:            true,
+					},
+					"facility": schema.StringAttribute{
+						MarkdownDescription: "Facility of the messages. e.g. `local0` (default: " + DefaultSyslogFacility + ")",
+						Optional:            true,
+					},
+					"include_command": schema.BoolAttribute{
+						MarkdownDescription: "Include the command in plain text in the start messages. The command may contain secrets, so only its SHA-256 is sent by default.",
+						Optional:            true,
+					},
+				},
+			},
+			"tracing": schema.SingleNestedBlock{
+				MarkdownDescription: "OpenTelemetry tracing of the plan and apply command executions. " +
+					"`TRACEPARENT` in the environment is used as the parent span and is propagated to the commands.",
+				Attributes: map[string]schema.Attribute{
+					"service_name": schema.StringAttribute{
+						MarkdownDescription: "Service name of the spans. (default: " + DefaultTracingServiceName + ")",
+						Optional:            true,
+					},
+					"endpoint": schema.StringAttribute{
+						MarkdownDescription: "OTLP/HTTP endpoint URL to export the spans. e.g. `http://localhost:4318/v1/traces` " +
+							"(default: configured with `OTEL_EXPORTER_OTLP_*` environment variables)",
+						Optional: true,
+					},
+					"file": schema.StringAttribute{
+						MarkdownDescription: "File to append the spans as JSON instead of exporting them to the endpoint.",
+						Optional:            true,
+						Validators: []validator.String{
+							stringvalidator.ConflictsWith(path.MatchRelative().AtParent().AtName("endpoint")),
+						},
+					},
+				},
+			},
+			"notification": schema.SingleNestedBlock{
+				MarkdownDescription: "Webhook to POST a JSON payload after each run.",
+				Attributes: map[string]schema.Attribute{
+					"url": schema.StringAttribute{
+						MarkdownDescription: "URL of the webhook.",
+						Optional:            true,
+					},
+					"headers": schema.MapAttribute{
+						MarkdownDescription: "HTTP headers of the request.",
+						ElementType:         types.StringType,
+						Optional:            true,
+						Sensitive:           true,
+					},
+					"events": schema.ListAttribute{
+						MarkdownDescription: "Events to notify. `success` and/or `failure`. (default: all events)",
+						ElementType:         types.StringType,
+						Optional:            true,
+						Validators: []validator.List{
+							listvalidator.ValueStringsAre(stringvalidator.OneOf(util.EventSuccess, util.EventFailure)),
+						},
+					},
+					"payload_template": schema.StringAttribute{
+						MarkdownDescription: "[Go template](https://pkg.go.dev/text/template) of the JSON payload. " +
+							"The fields of the default payload are available, e.g. `{{ .Event }}`, and `json` function quotes a value as JSON.",
+						Optional: true,
+					},
+					"secret": schema.StringAttribute{
+						MarkdownDescription: "Secret to sign the payload with HMAC-SHA256. The signature is sent in the `" + util.WebhookSignatureHeader + "` header.",
+						Optional:            true,
+						Sensitive:           true,
+					},
+					"retries": schema.Int64Attribute{
+						MarkdownDescription: "Number of retries on network errors and 5xx/429 responses. (default: " + strconv.Itoa(DefaultNotificationRetries) + ")",
+						Optional:            true,
+						Validators: []validator.Int64{
+							int64validator.AtLeast(0),
+						},
+					},
+					"timeout": schema.StringAttribute{
+						MarkdownDescription: "Timeout of each request. e.g. `30s` (default: " + DefaultNotificationTimeout + ")",
+						Optional:            true,
+					},
+					"include_command": schema.BoolAttribute{
+						MarkdownDescription: "Include the command in plain text in the payload. The command may contain secrets, so only its SHA-256 is sent by default.",
+						Optional:            true,
+					},
+				},
+			},
 		},
 	}
 }
@@ -53,10 +291,192 @@ func (p *OneshotProvider) Configure(ctx context.Context, req provider.ConfigureR
 		data.DefaultShell = types.StringValue(DefaultShell)
 	}
 
+	if data.LogEncryptionRecipient.IsNull() {
+		if v, ok := os.LookupEnv(EnvLogEncryptionRecipient); ok {
+			data.LogEncryptionRecipient = types.StringValue(v)
+		}
+	}
+
+	if !data.LogEncryptionRecipient.IsNull() {
+		recipients, err := util.ParseRecipients(data.LogEncryptionRecipient.ValueString())
+
+		if err != nil {
+			resp.Diagnostics.AddAttributeError(
+				path.Root("log_encryption_recipient"),
+				"Invalid Log Encryption Recipient",
+				fmt.Sprintf("Unable to parse log encryption recipient, got error: %s", err),
+			)
+
+			return
+		}
+
+		data.Recipients = recipients
+	}
+
+	if data.LogSink != nil {
+		sink := data.LogSink
+
+		if sink.Endpoint.IsNull() || sink.Bucket.IsNull() {
+			resp.Diagnostics.AddAttributeError(
+				path.Root("log_sink"),
+				"Invalid Log Sink",
+				"Both endpoint and bucket are required for log_sink.",
+			)
+
+			return
+		}
+
+		region := stringValueOrEnv(sink.Region, "AWS_REGION", DefaultLogSinkRegion)
+		accessKey := stringValueOrEnv(sink.AccessKey, "AWS_ACCESS_KEY_ID", "")
+		secretKey := stringValueOrEnv(sink.SecretKey, "AWS_SECRET_ACCESS_KEY", "")
+		data.Uploader = util.NewS3Uploader(sink.Endpoint.ValueString(), region, sink.Bucket.ValueString(), accessKey, secretKey)
+	}
+
+	if data.Syslog != nil {
+		syslog, err := util.NewSyslog(
+			stringValueOrDefault(data.Syslog.Network, DefaultSyslogNetwork),
+			stringValueOrDefault(data.Syslog.Address, DefaultSyslogAddress),
+			stringValueOrDefault(data.Syslog.Tag, DefaultSyslogTag),
+			stringValueOrDefault(data.Syslog.Facility, DefaultSyslogFacility),
+		)
+
+		if err != nil {
+			resp.Diagnostics.AddAttributeError(path.Root("syslog"), "Invalid Syslog", err.Error())
+			return
+		}
+
+		syslog.IncludeCommand = data.Syslog.IncludeCommand.ValueBool()
+		data.Syslogger = syslog
+	}
+
+	if !data.AuditLog.IsNull() {
+		auditLog, err := filepath.Abs(data.AuditLog.ValueString())
+
+		if err != nil {
+			resp.Diagnostics.AddAttributeError(path.Root("audit_log"), "Invalid Audit Log", err.Error())
+			return
+		}
+
+		data.AuditLogger = util.NewAuditLog(auditLog)
+		data.AuditLogger.RecordCommand = data.AuditLogCommand.ValueBool()
+
+		if !data.AuditLogEnvironment.IsNull() {
+			resp.Diagnostics.Append(data.AuditLogEnvironment.ElementsAs(ctx, &data.AuditLogger.Environment, false)...)
+		}
+	}
+
+	if !data.MetricsFile.IsNull() {
+		metricsFile, err := filepath.Abs(data.MetricsFile.ValueString())
+
+		if err != nil {
+			resp.Diagnostics.AddAttributeError(path.Root("metrics_file"), "Invalid Metrics File", err.Error())
+			return
+		}
+
+		data.Metrics = util.NewMetricsFile(metricsFile)
+	}
+
+	if data.Tracing != nil {
+		tp, err := util.NewTracerProvider(
+			ctx,
+			stringValueOrDefault(data.Tracing.ServiceName, DefaultTracingServiceName),
+			data.Tracing.Endpoint.ValueString(),
+			data.Tracing.File.ValueString(),
+		)
+
+		if err != nil {
+			resp.Diagnostics.AddAttributeError(path.Root("tracing"), "Invalid Tracing", err.Error())
+			return
+		}
+
+		data.Tracer = tp.Tracer("github.com/winebarrel/terraform-provider-oneshot")
+	}
+
+	if data.Notification != nil {
+		webhook, diags := newWebhook(ctx, data.Notification)
+		resp.Diagnostics.Append(diags...)
+
+		if resp.Diagnostics.HasError() {
+			return
+		}
+
+		data.Webhook = webhook
+	}
+
 	resp.DataSourceData = data
 	resp.ResourceData = data
 }
 
+func newWebhook(ctx context.Context, notification *NotificationModel) (*util.Webhook, diag.Diagnostics) {
+	var diags diag.Diagnostics
+
+	if notification.URL.IsNull() {
+		diags.AddAttributeError(path.Root("notification"), "Invalid Notification", "url is required for notification.")
+		return nil, diags
+	}
+
+	headers := map[string]string{}
+	diags.Append(notification.Headers.ElementsAs(ctx, &headers, false)...)
+	var events []string
+	diags.Append(notification.Events.ElementsAs(ctx, &events, false)...)
+
+	if diags.HasError() {
+		return nil, diags
+	}
+
+	retries := DefaultNotificationRetries
+
+	if !notification.Retries.IsNull() {
+		retries = int(notification.Retries.ValueInt64())
+	}
+
+	timeout, err := time.ParseDuration(stringValueOrDefault(notification.Timeout, DefaultNotificationTimeout))
+
+	if err != nil {
+		diags.AddAttributeError(path.Root("notification").AtName("timeout"), "Invalid Notification", err.Error())
+		return nil, diags
+	}
+
+	webhook, err := util.NewWebhook(
+		notification.URL.ValueString(),
+		headers,
+		events,
+		notification.PayloadTemplate.ValueString(),
+		notification.Secret.ValueString(),
+		retries,
+		timeout,
+	)
+
+	if err != nil {
+		diags.AddAttributeError(path.Root("notification"), "Invalid Notification", err.Error())
+		return nil, diags
+	}
+
+	webhook.IncludeCommand = notification.IncludeCommand.ValueBool()
+
+	return webhook, diags
+}
+
+func stringValueOrDefault(v types.String, defaultValue string) string {
+	if v.IsNull() {
+		return defaultValue
+	}
+
+	return v.ValueString()
+}
+
+func stringValueOrEnv(v types.String, env string, defaultValue string) string {
+	if !v.IsNull() {
+		return v.ValueString()
+	}
+
+	if s, ok := os.LookupEnv(env); ok {
+		return s
+	}
+
+	return defaultValue
+}
+
 func (p *OneshotProvider) Resources(ctx context.Context) []func() resource.Resource {
 	return []func() resource.Resource{
 		NewRunResource,
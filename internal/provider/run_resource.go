@@ -2,16 +2,33 @@ package provider
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io/fs"
+	"maps"
 	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
 
 	"time"
 
+	"github.com/google/uuid"
+	"github.com/hashicorp/terraform-plugin-framework-validators/boolvalidator"
+	"github.com/hashicorp/terraform-plugin-framework-validators/int64validator"
 	"github.com/hashicorp/terraform-plugin-framework-validators/mapvalidator"
 	"github.com/hashicorp/terraform-plugin-framework-validators/stringvalidator"
+	"github.com/hashicorp/terraform-plugin-framework/attr"
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/path"
 	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/resource/identityschema"
 	"github.com/hashicorp/terraform-plugin-framework/resource/schema"
-	"github.com/hashicorp/terraform-plugin-framework/resource/schema/mapplanmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/boolplanmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/int64planmodifier"
 	"github.com/hashicorp/terraform-plugin-framework/resource/schema/planmodifier"
 	"github.com/hashicorp/terraform-plugin-framework/resource/schema/stringdefault"
 	"github.com/hashicorp/terraform-plugin-framework/resource/schema/stringplanmodifier"
@@ -20,71 +37,463 @@ import (
 	"github.com/winebarrel/terraform-provider-oneshot/internal/util"
 )
 
-var _ resource.ResourceWithModifyPlan = &RunResource{}
+var (
+	_ resource.ResourceWithModifyPlan   = &RunResource{}
+	_ resource.ResourceWithUpgradeState = &RunResource{}
+	_ resource.ResourceWithIdentity     = &RunResource{}
+)
 
 func NewRunResource() resource.Resource {
 	return &RunResource{}
 }
 
 type RunResource struct {
-	defaultShell string
+	providerData OneshotProviderModel
 }
 
 type RunResourceModel struct {
-	Command       types.String `tfsdk:"command"`
-	PlanCommand   types.String `tfsdk:"plan_command"`
-	Shell         types.String `tfsdk:"shell"`
-	StdoutLog     types.String `tfsdk:"stdout_log"`
-	StderrLog     types.String `tfsdk:"stderr_log"`
-	PlanStdoutLog types.String `tfsdk:"plan_stdout_log"`
-	PlanStderrLog types.String `tfsdk:"plan_stderr_log"`
-	WorkingDir    types.String `tfsdk:"working_dir"`
-	RunAt         types.String `tfsdk:"run_at"`
-	Triggers      types.Map    `tfsdk:"triggers"`
-}
-
-func (data RunResourceModel) Run(shell string) error {
+	ID                    types.String `tfsdk:"id"`
+	Command               types.String `tfsdk:"command"`
+	PlanCommand           types.String `tfsdk:"plan_command"`
+	Shell                 types.String `tfsdk:"shell"`
+	StdoutLog             types.String `tfsdk:"stdout_log"`
+	StderrLog             types.String `tfsdk:"stderr_log"`
+	PlanStdoutLog         types.String `tfsdk:"plan_stdout_log"`
+	PlanStderrLog         types.String `tfsdk:"plan_stderr_log"`
+	WorkingDir            types.String `tfsdk:"working_dir"`
+	StartedAt             types.String `tfsdk:"started_at"`
+	FinishedAt            types.String `tfsdk:"finished_at"`
+	Duration              types.String `tfsdk:"duration"`
+	StdoutLogURL          types.String `tfsdk:"stdout_log_url"`
+	StderrLogURL          types.String `tfsdk:"stderr_log_url"`
+	Triggers              types.Map    `tfsdk:"triggers"`
+	MetricsLabel          types.String `tfsdk:"metrics_label"`
+	CheckCommand          types.String `tfsdk:"check_command"`
+	CheckExitCode         types.Int64  `tfsdk:"check_exit_code"`
+	UpdateCommand         types.String `tfsdk:"update_command"`
+	Environment           types.Map    `tfsdk:"environment"`
+	UpdateTriggers        types.Map    `tfsdk:"update_triggers"`
+	Execution             types.Object `tfsdk:"execution"`
+	PlanOutput            types.String `tfsdk:"plan_output"`
+	WarnPlanOutput        types.Bool   `tfsdk:"warn_plan_output"`
+	RecordPlanOutput      types.Bool   `tfsdk:"record_plan_output"`
+	PlanExitCodeSemantics types.String `tfsdk:"plan_exit_code_semantics"`
+	SkipReason            types.String `tfsdk:"skip_reason"`
+	PlanFingerprint       types.String `tfsdk:"plan_fingerprint"`
+	PreCommand            types.String `tfsdk:"pre_command"`
+	PostCommand           types.String `tfsdk:"post_command"`
+	RollbackCommand       types.String `tfsdk:"rollback_command"`
+	ExitCode              types.Int64  `tfsdk:"exit_code"`
+	RollbackExitCode      types.Int64  `tfsdk:"rollback_exit_code"`
+	Creates               types.String `tfsdk:"creates"`
+	Removes               types.String `tfsdk:"removes"`
+	OnlyIf                types.String `tfsdk:"only_if"`
+	Unless                types.String `tfsdk:"unless"`
+	PreviewGuards         types.Bool   `tfsdk:"preview_guards"`
+	Skipped               types.Bool   `tfsdk:"skipped"`
+	ExpectStdoutRegex     types.String `tfsdk:"expect_stdout_regex"`
+	FailOnOutputRegex     types.String `tfsdk:"fail_on_output_regex"`
+	VerifyCommand         types.String `tfsdk:"verify_command"`
+}
+
+type RunResourceIdentityModel struct {
+	ID types.String `tfsdk:"id"`
+}
+
+// MaxPlanOutputWarningLength is the maximum length of the plan output shown in the warning.
+const MaxPlanOutputWarningLength = 2000
+
+const (
+	PlanExitCodeSemanticsDefault  = "default"
+	PlanExitCodeSemanticsDetailed = "detailed"
+	// PlanExitCodeChangesPending is the exit code of plan_command that means the command needs to be executed
+	// with the detailed exit code semantics.
+	PlanExitCodeChangesPending = 2
+)
+
+const (
+	PhasePlan     = "plan"
+	PhaseApply    = "apply"
+	PhaseCheck    = "check"
+	PhaseUpdate   = "update"
+	PhasePre      = "pre"
+	PhasePost     = "post"
+	PhaseRollback = "rollback"
+	PhaseGuard    = "guard"
+	PhaseVerify   = "verify"
+)
+
+func (data RunResourceModel) Run(ctx context.Context, providerData OneshotProviderModel, runID string) (*Execution, error) {
+	return data.execute(ctx, providerData, runID, PhaseApply, data.Command.ValueString(), data.StdoutLog.ValueString(), data.StderrLog.ValueString())
+}
+
+// Plan runs the plan command.
+// The fingerprint written by the plan command to ONESHOT_FINGERPRINT_FILE is set to the execution.
+func (data RunResourceModel) Plan(ctx context.Context, providerData OneshotProviderModel, runID string) (*Execution, error) {
+	f, err := os.CreateTemp("", "oneshot-fingerprint-*")
+
+	if err != nil {
+		return nil, err
+	}
+
+	f.Close()
+	defer os.Remove(f.Name()) //nolint:errcheck
+
+	exec, err := data.execute(ctx, providerData, runID, PhasePlan, data.PlanCommand.ValueString(), data.PlanStdoutLog.ValueString(), data.PlanStderrLog.ValueString(),
+		"ONESHOT_PLAN=1", "ONESHOT_FINGERPRINT_FILE="+f.Name())
+
+	if exec != nil {
+		fingerprint, _ := os.ReadFile(f.Name())
+		exec.Fingerprint = strings.TrimSpace(string(fingerprint))
+	}
+
+	return exec, err
+}
+
+func (data RunResourceModel) Check(ctx context.Context, providerData OneshotProviderModel, runID string) (*Execution, error) {
+	return data.execute(ctx, providerData, runID, PhaseCheck, data.CheckCommand.ValueString(), "", "", "ONESHOT_CHECK=1")
+}
+
+// RunHook runs the pre or post hook command.
+// The hook commands do not write the logs so as not to overwrite the logs of the command.
+func (data RunResourceModel) RunHook(ctx context.Context, providerData OneshotProviderModel, runID string, phase string, command string, extraEnvs ...string) (*Execution, error) {
+	return data.execute(ctx, providerData, runID, phase, command, "", "", append([]string{"ONESHOT_HOOK=" + phase}, extraEnvs...)...)
+}
+
+// Rollback runs the rollback command with the exit code and the log files of the failed command.
+func (data RunResourceModel) Rollback(ctx context.Context, providerData OneshotProviderModel, runID string, failed *Execution) (*Execution, error) {
+	return data.execute(ctx, providerData, runID, PhaseRollback, data.RollbackCommand.ValueString(), "", "",
+		"ONESHOT_EXIT_CODE="+strconv.Itoa(failed.ExitCode),
+		"ONESHOT_FAILED_STDOUT_LOG="+failed.StdoutLog,
+		"ONESHOT_FAILED_STDERR_LOG="+failed.StderrLog,
+	)
+}
+
+// RunUpdate runs the update command with the old and new values of environment and update_triggers in JSON.
+func (data RunResourceModel) RunUpdate(ctx context.Context, providerData OneshotProviderModel, runID string, state RunResourceModel) (*Execution, error) {
+	return data.execute(ctx, providerData, runID, PhaseUpdate, data.UpdateCommand.ValueString(), data.StdoutLog.ValueString(), data.StderrLog.ValueString(),
+		"ONESHOT_UPDATE=1",
+		"ONESHOT_OLD_ENVIRONMENT="+mapJSON(state.Environment),
+		"ONESHOT_NEW_ENVIRONMENT="+mapJSON(data.Environment),
+		"ONESHOT_OLD_UPDATE_TRIGGERS="+mapJSON(state.UpdateTriggers),
+		"ONESHOT_NEW_UPDATE_TRIGGERS="+mapJSON(data.UpdateTriggers),
+	)
+}
+
+// PlanSkipReason interprets the result of the plan command and returns the reason to skip the command.
+// With the detailed exit code semantics, exit code 0 means nothing to do and 2 means changes pending, like `terraform plan -detailed-exitcode`.
+func (data RunResourceModel) PlanSkipReason(err error) (types.String, error) {
+	if data.PlanExitCodeSemantics.ValueString() != PlanExitCodeSemanticsDetailed {
+		return types.StringNull(), err
+	}
+
+	switch util.ExitCode(err) {
+	case 0:
+		return types.StringValue("plan_command exited with code 0 (nothing to do)"), nil
+	case PlanExitCodeChangesPending:
+		return types.StringNull(), nil
+	}
+
+	return types.StringNull(), err
+}
+
+// CheckOutput checks the output of the command with expect_stdout_regex and fail_on_output_regex.
+func (data RunResourceModel) CheckOutput(exec *Execution) error {
+	if !data.FailOnOutputRegex.IsNull() {
+		re, err := regexp.Compile(data.FailOnOutputRegex.ValueString())
+
+		if err != nil {
+			return fmt.Errorf("invalid fail_on_output_regex: %w", err)
+		}
+
+		for _, output := range []struct {
+			name string
+			text string
+		}{
+			{"stdout", exec.Stdout},
+			{"stderr", exec.Stderr},
+		} {
+			for i, line := range strings.Split(output.text, "\n") {
+				if re.MatchString(line) {
+					return fmt.Errorf("%s line %d matches fail_on_output_regex: %q", output.name, i+1, line)
+				}
+			}
+		}
+	}
+
+	if !data.ExpectStdoutRegex.IsNull() {
+		re, err := regexp.Compile(data.ExpectStdoutRegex.ValueString())
+
+		if err != nil {
+			return fmt.Errorf("invalid expect_stdout_regex: %w", err)
+		}
+
+		if !re.MatchString(exec.Stdout) {
+			return fmt.Errorf("stdout does not match expect_stdout_regex: %q", data.ExpectStdoutRegex.ValueString())
+		}
+	}
+
+	return nil
+}
+
+// HasGuards returns true if any of the guard conditions is set.
+func (data RunResourceModel) HasGuards() bool {
+	return !data.Creates.IsNull() || !data.Removes.IsNull() || !data.OnlyIf.IsNull() || !data.Unless.IsNull()
+}
+
+// UpdateRequired returns true if the update command should be executed for the changes from the state.
+func (data RunResourceModel) UpdateRequired(state RunResourceModel) bool {
+	if data.UpdateCommand.IsNull() {
+		return false
+	}
+
+	return !data.Environment.Equal(state.Environment) || !data.UpdateTriggers.Equal(state.UpdateTriggers)
+}
+
+// environ returns the environment variables of the environment attribute.
+func (data RunResourceModel) environ() []string {
+	var envs []string
+
+	for k, v := range data.Environment.Elements() {
+		if s, ok := v.(types.String); ok {
+			envs = append(envs, k+"="+s.ValueString())
+		}
+	}
+
+	sort.Strings(envs)
+
+	return envs
+}
+
+// UnknownPlanAttributes returns the names of the attributes used by the plan command that are unknown at plan time.
+func (data RunResourceModel) UnknownPlanAttributes() []string {
+	var names []string
+
+	for _, v := range []struct {
+		name  string
+		value attr.Value
+	}{
+		{"plan_command", data.PlanCommand},
+		{"shell", data.Shell},
+		{"working_dir", data.WorkingDir},
+		{"plan_stdout_log", data.PlanStdoutLog},
+		{"plan_stderr_log", data.PlanStderrLog},
+		{"environment", data.Environment},
+	} {
+		if v.value.IsUnknown() {
+			names = append(names, v.name)
+		}
+	}
+
+	for _, v := range data.Environment.Elements() {
+		if v.IsUnknown() {
+			names = append(names, "environment")
+			break
+		}
+	}
+
+	return names
+}
+
+func mapJSON(m types.Map) string {
+	strs := map[string]string{}
+
+	for k, v := range m.Elements() {
+		if s, ok := v.(types.String); ok {
+			strs[k] = s.ValueString()
+		}
+	}
+
+	b, _ := json.Marshal(strs)
+
+	return string(b)
+}
+
+// Drifted returns true if the exit code of the check command means that the effect of the command has been undone.
+func (data RunResourceModel) Drifted(exitCode int) bool {
+	if data.CheckExitCode.IsNull() {
+		return exitCode > 0
+	}
+
+	return int64(exitCode) == data.CheckExitCode.ValueInt64()
+}
+
+func (data RunResourceModel) execute(ctx context.Context, providerData OneshotProviderModel, runID string, phase string, command string, stdoutLog string, stderrLog string, extraEnvs ...string) (*Execution, error) {
+	shell := providerData.DefaultShell.ValueString()
+
 	if !data.Shell.IsNull() {
 		shell = data.Shell.ValueString()
 	}
 
+	workspace := terraformWorkspace()
+	environ := data.environ()
+
 	if !data.WorkingDir.IsNull() {
 		cwd, _ := os.Getwd()
 		err := os.Chdir(data.WorkingDir.ValueString())
 
 		if err != nil {
-			return err
+			return nil, err
 		}
 
 		defer os.Chdir(cwd) //nolint:errcheck
 	}
 
-	cmd := util.NewCmd(shell, data.StdoutLog.ValueString(), data.StderrLog.ValueString())
-	_, _, err := cmd.Run(data.Command.ValueString())
+	workingDir, _ := os.Getwd()
+	cmd := util.NewCmd(shell, stdoutLog, stderrLog)
+	cmd.Recipients = providerData.Recipients
+
+	var syslog *util.SyslogConn
+	var syslogErr error
+	var stdout, stderr *util.SyslogLineWriter
+	params := map[string]string{"run_id": runID, "phase": phase}
+
+	if providerData.Syslogger != nil {
+		// NOTE: Run the command without syslog if it is unavailable, like the other sinks
+		syslog, syslogErr = providerData.Syslogger.Dial()
+	}
+
+	if syslog != nil {
+		defer syslog.Close()
+		startParams := map[string]string{"command_sha256": util.CommandHash(command)}
+		maps.Copy(startParams, params)
+		syslog.Send(util.SeverityNotice, "start", startParams, syslogCommand(providerData.Syslogger, command)) //nolint:errcheck
+		stdout = syslog.LineWriter(util.SeverityInfo, "stdout", params)
+		stderr = syslog.LineWriter(util.SeverityWarning, "stderr", params)
+		cmd.StdoutTee = stdout
+		cmd.StderrTee = stderr
+	}
+
+	ctx, span := providerData.startSpan(ctx, "oneshot."+phase)
+	defer span.End()
+	extraEnvs = append(extraEnvs, "ONESHOT_RUN_ID="+runID)
+	extraEnvs = append(extraEnvs, util.TraceEnv(ctx)...)
+
+	exec := &Execution{
+		RunID:      runID,
+		Label:      data.metricsLabel(),
+		Phase:      phase,
+		Command:    command,
+		Environ:    environ,
+		ExtraEnvs:  extraEnvs,
+		Shell:      shell,
+		WorkingDir: workingDir,
+		Workspace:  workspace,
+		StdoutLog:  absPath(stdoutLog),
+		StderrLog:  absPath(stderrLog),
+		StartedAt:  time.Now(),
+		SyslogErr:  syslogErr,
+	}
+
+	stdoutStr, stderrStr, err := cmd.Run(command, append(environ, extraEnvs...)...)
+	exec.FinishedAt = time.Now()
+	exec.Stdout = stdoutStr
+	exec.Stderr = stderrStr
+	exec.ExitCode = util.ExitCode(err)
+	exec.Succeeded = data.succeeded(phase, exec.ExitCode)
+	exec.StdoutBytes = cmd.StdoutBytes
+	exec.StderrBytes = cmd.StderrBytes
+	exec.Usage = cmd.Usage
+	exec.setSpanAttributes(span)
 
-	return err
+	if syslog != nil {
+		stdout.Flush()
+		stderr.Flush()
+		params["exit_code"] = strconv.Itoa(exec.ExitCode)
+
+		if !exec.Succeeded {
+			syslog.Send(util.SeverityErr, "finish", params, fmt.Sprintf("failed (exit code %d)", exec.ExitCode)) //nolint:errcheck
+		} else {
+			syslog.Send(util.SeverityNotice, "finish", params, "succeeded") //nolint:errcheck
+		}
+	}
+
+	return exec, err
 }
 
-func (data RunResourceModel) Plan(shell string) error {
-	if !data.Shell.IsNull() {
-		shell = data.Shell.ValueString()
+// succeeded returns true if the exit code means success.
+// With the detailed exit code semantics, the plan command exits with 2 when the changes are pending, which is also a success.
+func (data RunResourceModel) succeeded(phase string, exitCode int) bool {
+	if phase == PhasePlan && data.PlanExitCodeSemantics.ValueString() == PlanExitCodeSemanticsDetailed {
+		return exitCode == 0 || exitCode == PlanExitCodeChangesPending
 	}
 
-	if !data.WorkingDir.IsNull() {
-		cwd, _ := os.Getwd()
-		err := os.Chdir(data.WorkingDir.ValueString())
+	return exitCode == 0
+}
+
+// metricsLabel returns the label of the metrics.
+// If metrics_label is not set, the prefix of the command hash is used to identify the run.
+func (data RunResourceModel) metricsLabel() string {
+	if !data.MetricsLabel.IsNull() {
+		return data.MetricsLabel.ValueString()
+	}
+
+	return util.CommandHash(data.Command.ValueString())[:12]
+}
+
+// SetTimes sets the execution times.
+// If the command was not executed, the current time is used.
+func (data *RunResourceModel) SetTimes(exec *Execution) {
+	startedAt := time.Now()
+	finishedAt := startedAt
+
+	if exec != nil {
+		startedAt = exec.StartedAt
+		finishedAt = exec.FinishedAt
+	}
+
+	data.StartedAt = types.StringValue(startedAt.Format(time.RFC3339))
+	data.FinishedAt = types.StringValue(finishedAt.Format(time.RFC3339))
+	data.Duration = types.StringValue(finishedAt.Sub(startedAt).String())
+}
+
+func (data *RunResourceModel) UploadLogs(providerData OneshotProviderModel, runID string) error {
+	data.StdoutLogURL = types.StringNull()
+	data.StderrLogURL = types.StringNull()
+
+	if providerData.Uploader == nil {
+		return nil
+	}
+
+	prefix := providerData.LogSink.Prefix.ValueString() + runID + "/"
+
+	for _, log := range []struct {
+		key  string
+		path string
+		url  *types.String
+	}{
+		{"stdout.log", data.StdoutLog.ValueString(), &data.StdoutLogURL},
+		{"stderr.log", data.StderrLog.ValueString(), &data.StderrLogURL},
+	} {
+		if log.path == "" {
+			continue
+		}
+
+		body, err := os.ReadFile(data.workingPath(log.path))
 
 		if err != nil {
 			return err
 		}
 
-		defer os.Chdir(cwd) //nolint:errcheck
+		url, err := providerData.Uploader.Upload(prefix+log.key, body)
+
+		if err != nil {
+			return err
+		}
+
+		*log.url = types.StringValue(url)
 	}
 
-	cmd := util.NewCmd(shell, data.PlanStdoutLog.ValueString(), data.PlanStderrLog.ValueString())
-	_, _, err := cmd.Run(data.PlanCommand.ValueString(), "ONESHOT_PLAN=1")
+	return nil
+}
+
+// workingPath returns the path relative to the working directory.
+func (data RunResourceModel) workingPath(name string) string {
+	if data.WorkingDir.IsNull() || filepath.IsAbs(name) {
+		return name
+	}
 
-	return err
+	return filepath.Join(data.WorkingDir.ValueString(), name)
 }
 
 func (r *RunResource) Metadata(ctx context.Context, req resource.MetadataRequest, resp *resource.MetadataResponse) {
@@ -93,26 +502,34 @@ func (r *RunResource) Metadata(ctx context.Context, req resource.MetadataRequest
 
 func (r *RunResource) Schema(ctx context.Context, req resource.SchemaRequest, resp *resource.SchemaResponse) {
 	resp.Schema = schema.Schema{
+		Version: 1,
 		Attributes: map[string]schema.Attribute{
+			"id": schema.StringAttribute{
+				MarkdownDescription: "Run ID. It is passed to the command as `ONESHOT_RUN_ID` and used in the audit log and the log sink.",
+				Computed:            true,
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.UseStateForUnknown(),
+				},
+			},
 			"command": schema.StringAttribute{
 				MarkdownDescription: "Command to execute",
 				Required:            true,
 				PlanModifiers: []planmodifier.String{
-					stringplanmodifier.RequiresReplace(),
+					requiresReplaceUnlessMoved(),
 				},
 			},
 			"plan_command": schema.StringAttribute{
 				MarkdownDescription: "Command to plan.",
 				Optional:            true,
 				PlanModifiers: []planmodifier.String{
-					stringplanmodifier.RequiresReplace(),
+					requiresReplaceUnlessMoved(),
 				},
 			},
 			"shell": schema.StringAttribute{
 				MarkdownDescription: "Shell to execute the command.",
 				Optional:            true,
 				PlanModifiers: []planmodifier.String{
-					stringplanmodifier.RequiresReplace(),
+					requiresReplaceUnlessMoved(),
 				},
 			},
 			"stdout_log": schema.StringAttribute{
@@ -143,8 +560,24 @@ func (r *RunResource) Schema(ctx context.Context, req resource.SchemaRequest, re
 				MarkdownDescription: "Working directory.",
 				Optional:            true,
 			},
-			"run_at": schema.StringAttribute{
-				MarkdownDescription: "Command execution time.",
+			"started_at": schema.StringAttribute{
+				MarkdownDescription: "Time the command started, in RFC 3339 format.",
+				Computed:            true,
+			},
+			"finished_at": schema.StringAttribute{
+				MarkdownDescription: "Time the command finished, in RFC 3339 format.",
+				Computed:            true,
+			},
+			"duration": schema.StringAttribute{
+				MarkdownDescription: "Duration of the command. e.g. `1.5s`",
+				Computed:            true,
+			},
+			"stdout_log_url": schema.StringAttribute{
+				MarkdownDescription: "URL of the stdout log uploaded to the log sink.",
+				Computed:            true,
+			},
+			"stderr_log_url": schema.StringAttribute{
+				MarkdownDescription: "URL of the stderr log uploaded to the log sink.",
 				Computed:            true,
 			},
 			"triggers": schema.MapAttribute{
@@ -156,9 +589,199 @@ func (r *RunResource) Schema(ctx context.Context, req resource.SchemaRequest, re
 					mapvalidator.ValueStringsAre(stringvalidator.LengthAtLeast(0)),
 				},
 				PlanModifiers: []planmodifier.Map{
-					mapplanmodifier.RequiresReplace(),
+					requiresReplaceMapUnlessMoved(),
+				},
+			},
+			"execution": schema.SingleNestedAttribute{
+				MarkdownDescription: "Resource usage of the command execution.",
+				Computed:            true,
+				Attributes: map[string]schema.Attribute{
+					"duration_seconds": schema.Float64Attribute{
+						MarkdownDescription: "Wall-clock duration of the command.",
+						Computed:            true,
+					},
+					"user_cpu_seconds": schema.Float64Attribute{
+						MarkdownDescription: "User CPU time of the command.",
+						Computed:            true,
+					},
+					"system_cpu_seconds": schema.Float64Attribute{
+						MarkdownDescription: "System CPU time of the command.",
+						Computed:            true,
+					},
+					"max_rss_bytes": schema.Int64Attribute{
+						MarkdownDescription: "Maximum resident set size of the command. (0 on Windows)",
+						Computed:            true,
+					},
+					"signal": schema.StringAttribute{
+						MarkdownDescription: "Signal that terminated the command, if any.",
+						Computed:            true,
+					},
+				},
+			},
+			"check_command": schema.StringAttribute{
+				MarkdownDescription: "Command to check whether the effect of the command still exists, executed on refresh. " +
+					"If it exits with non-zero (or `check_exit_code`), the resource is removed from the state so that the command is executed again.",
+				Optional: true,
+			},
+			"check_exit_code": schema.Int64Attribute{
+				MarkdownDescription: "Exit code of `check_command` that means the effect of the command has been undone. " +
+					"Other non-zero exit codes are reported as errors. (default: any non-zero exit code)",
+				Optional: true,
+				Validators: []validator.Int64{
+					int64validator.Between(1, 255),
+					int64validator.AlsoRequires(path.MatchRoot("check_command")),
+				},
+			},
+			"update_command": schema.StringAttribute{
+				MarkdownDescription: "Command executed in place when `environment` or `update_triggers` is changed, instead of replacing the resource. " +
+					"The old and new values are passed in JSON as `ONESHOT_OLD_ENVIRONMENT`, `ONESHOT_NEW_ENVIRONMENT`, " +
+					"`ONESHOT_OLD_UPDATE_TRIGGERS` and `ONESHOT_NEW_UPDATE_TRIGGERS`. The output is written to `stdout_log` and `stderr_log`.",
+				Optional: true,
+			},
+			"environment": schema.MapAttribute{
+				MarkdownDescription: "Environment variables of the commands.",
+				ElementType:         types.StringType,
+				Optional:            true,
+			},
+			"update_triggers": schema.MapAttribute{
+				MarkdownDescription: "Arbitrary values whose changes execute `update_command` in place.",
+				ElementType:         types.StringType,
+				Optional:            true,
+			},
+			"plan_output": schema.StringAttribute{
+				MarkdownDescription: "Stdout of `plan_command`, set when planning so that it appears in the plan if `record_plan_output` is true. It is stored in the state in plain text.",
+				Computed:            true,
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.UseStateForUnknown(),
+				},
+			},
+			"plan_exit_code_semantics": schema.StringAttribute{
+				MarkdownDescription: "Meaning of the exit code of `plan_command`. Valid values are `default` (non-zero means error) and " +
+					"`detailed` (0 means nothing to do, 2 means changes pending and others mean error, like `terraform plan -detailed-exitcode`). " +
+					"If `detailed` and nothing to do, the command is skipped and the reason is recorded in `skip_reason`.",
+				Optional: true,
+				Validators: []validator.String{
+					stringvalidator.OneOf(PlanExitCodeSemanticsDefault, PlanExitCodeSemanticsDetailed),
+					stringvalidator.AlsoRequires(path.MatchRoot("plan_command")),
+				},
+			},
+			"plan_fingerprint": schema.StringAttribute{
+				MarkdownDescription: "Fingerprint written by `plan_command` to the file of `ONESHOT_FINGERPRINT_FILE`. " +
+					"If it is set, `plan_command` is executed again before the command at apply time, and the apply is aborted if the fingerprint differs.",
+				Computed: true,
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.UseStateForUnknown(),
+				},
+			},
+			"skip_reason": schema.StringAttribute{
+				MarkdownDescription: "Reason why the command was skipped. Null if the command was executed.",
+				Computed:            true,
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.UseStateForUnknown(),
+				},
+			},
+			"warn_plan_output": schema.BoolAttribute{
+				MarkdownDescription: fmt.Sprintf("Show the stdout of `plan_command` as a warning diagnostic, truncated to %d characters.", MaxPlanOutputWarningLength),
+				Optional:            true,
+			},
+			"record_plan_output": schema.BoolAttribute{
+				MarkdownDescription: "Set the stdout of `plan_command` to `plan_output`. " +
+					"The stdout must be deterministic (e.g. no timestamps or `ONESHOT_RUN_ID`), because `plan_command` is executed again " +
+					"when Terraform plans the resource at apply time, and Terraform aborts the apply if `plan_output` differs from the plan.",
+				Optional: true,
+				Validators: []validator.Bool{
+					boolvalidator.AlsoRequires(path.MatchRoot("plan_command")),
+				},
+			},
+			"pre_command": schema.StringAttribute{
+				MarkdownDescription: "Command executed before the command, after `before_each` of the provider. If it fails, the command is not executed, but `post_command` is.",
+				Optional:            true,
+			},
+			"post_command": schema.StringAttribute{
+				MarkdownDescription: "Command executed after the command, before `after_each` of the provider, even if the command or `pre_command` failed. " +
+					"The exit code of the command is passed as `ONESHOT_EXIT_CODE` if it was executed.",
+				Optional: true,
+			},
+			"rollback_command": schema.StringAttribute{
+				MarkdownDescription: "Command executed when the command fails, before `post_command`. The exit code and the log files of the command are passed as " +
+					"`ONESHOT_EXIT_CODE`, `ONESHOT_FAILED_STDOUT_LOG` and `ONESHOT_FAILED_STDERR_LOG`.",
+				Optional: true,
+			},
+			"exit_code": schema.Int64Attribute{
+				MarkdownDescription: "Exit code of the command, or 1 if the command exited with zero but failed the verification. Null if the command was not executed.",
+				Computed:            true,
+				PlanModifiers: []planmodifier.Int64{
+					int64planmodifier.UseStateForUnknown(),
+				},
+			},
+			"rollback_exit_code": schema.Int64Attribute{
+				MarkdownDescription: "Exit code of `rollback_command`. Null if it was not executed.",
+				Computed:            true,
+				PlanModifiers: []planmodifier.Int64{
+					int64planmodifier.UseStateForUnknown(),
 				},
 			},
+			"creates": schema.StringAttribute{
+				MarkdownDescription: "Path whose existence skips the command. Relative to `working_dir`.",
+				Optional:            true,
+			},
+			"removes": schema.StringAttribute{
+				MarkdownDescription: "Path whose absence skips the command. Relative to `working_dir`.",
+				Optional:            true,
+			},
+			"only_if": schema.StringAttribute{
+				MarkdownDescription: "Guard command. If it exits with non-zero, the command is skipped.",
+				Optional:            true,
+			},
+			"unless": schema.StringAttribute{
+				MarkdownDescription: "Guard command. If it exits with zero, the command is skipped.",
+				Optional:            true,
+			},
+			"preview_guards": schema.BoolAttribute{
+				MarkdownDescription: "Evaluate the guard conditions (`creates`, `removes`, `only_if` and `unless`) also at plan time, " +
+					"and show a warning if the command will be skipped. The guard conditions are always evaluated again at apply time.",
+				Optional: true,
+			},
+			"skipped": schema.BoolAttribute{
+				MarkdownDescription: "Whether the command was skipped by `plan_exit_code_semantics` or the guard conditions. The reason is recorded in `skip_reason`.",
+				Computed:            true,
+				PlanModifiers: []planmodifier.Bool{
+					boolplanmodifier.UseStateForUnknown(),
+				},
+			},
+			"expect_stdout_regex": schema.StringAttribute{
+				MarkdownDescription: "Regular expression that the stdout of the command must match. If it does not match, the run is marked failed even if the command exits with zero.",
+				Optional:            true,
+				Validators: []validator.String{
+					validRegex(),
+				},
+			},
+			"fail_on_output_regex": schema.StringAttribute{
+				MarkdownDescription: "Regular expression that marks the run failed if any line of the stdout or stderr of the command matches it.",
+				Optional:            true,
+				Validators: []validator.String{
+					validRegex(),
+				},
+			},
+			"verify_command": schema.StringAttribute{
+				MarkdownDescription: "Command executed after the command succeeded. If it exits with non-zero, the run is marked failed.",
+				Optional:            true,
+			},
+			"metrics_label": schema.StringAttribute{
+				MarkdownDescription: "Value of the `label` label of the metrics written to the `metrics_file`. (default: first 12 characters of the SHA-256 of the command)",
+				Optional:            true,
+			},
+		},
+	}
+}
+
+func (r *RunResource) IdentitySchema(ctx context.Context, req resource.IdentitySchemaRequest, resp *resource.IdentitySchemaResponse) {
+	resp.IdentitySchema = identityschema.Schema{
+		Attributes: map[string]identityschema.Attribute{
+			"id": identityschema.StringAttribute{
+				Description:       "Run ID.",
+				RequiredForImport: true,
+			},
 		},
 	}
 }
@@ -177,7 +800,7 @@ func (r *RunResource) Configure(ctx context.Context, req resource.ConfigureReque
 		)
 	}
 
-	r.defaultShell = providerData.DefaultShell.ValueString()
+	r.providerData = providerData
 }
 
 func (r *RunResource) Create(ctx context.Context, req resource.CreateRequest, resp *resource.CreateResponse) {
@@ -188,23 +811,364 @@ func (r *RunResource) Create(ctx context.Context, req resource.CreateRequest, re
 		return
 	}
 
-	err := data.Run(r.defaultShell)
+	runID := uuid.NewString()
+	data.ID = types.StringValue(runID)
+
+	if !data.PlanFingerprint.IsNull() {
+		resp.Diagnostics.Append(r.verifyPlanFingerprint(ctx, data, runID)...)
+
+		if resp.Diagnostics.HasError() {
+			return
+		}
+	}
+
+	if !data.SkipReason.IsNull() && !data.SkipReason.IsUnknown() {
+		// NOTE: plan_command reported nothing to do
+		data.Skipped = types.BoolValue(true)
+	} else {
+		skipReason, diags := r.evaluateGuards(ctx, data, runID)
+		resp.Diagnostics.Append(diags...)
+
+		if resp.Diagnostics.HasError() {
+			return
+		}
+
+		data.SkipReason = skipReason
+		data.Skipped = types.BoolValue(!skipReason.IsNull())
+	}
+
+	if data.Skipped.ValueBool() {
+		// NOTE: Do not run command
+		data.SetTimes(nil)
+		data.Execution = (*Execution)(nil).Object()
+		data.StdoutLogURL = types.StringNull()
+		data.StderrLogURL = types.StringNull()
+		data.ExitCode = types.Int64Null()
+		data.RollbackExitCode = types.Int64Null()
+		resp.Diagnostics.Append(resp.State.Set(ctx, &data)...)
+		resp.Diagnostics.Append(resp.Identity.Set(ctx, RunResourceIdentityModel{ID: data.ID})...)
+		return
+	}
+
+	// NOTE: The post hooks are in the reverse order of the matching pre hooks
+	postHooks := []types.String{data.PostCommand, r.providerData.AfterEach}
+	started, diags := r.runHooks(ctx, data, runID, PhasePre, nil, r.providerData.BeforeEach, data.PreCommand)
+	resp.Diagnostics.Append(diags...)
+
+	if diags.HasError() {
+		// NOTE: Run the post hooks of the started pre hooks even if the command is not executed
+		_, diags = r.runHooks(ctx, data, runID, PhasePost, nil, postHooks[len(postHooks)-started:]...)
+		resp.Diagnostics.Append(diags...)
+		return
+	}
+
+	exec, err := data.Run(ctx, r.providerData, runID)
+	exitCode := util.ExitCode(err)
 
 	if err != nil {
 		resp.Diagnostics.AddError("Run Command Error", fmt.Sprintf("Unable to run command, got error: %s", err))
+	} else {
+		// NOTE: Mark the run failed if the output indicates an error despite the zero exit status
+		diags := r.verify(ctx, data, runID, exec)
+		resp.Diagnostics.Append(diags...)
+
+		if diags.HasError() {
+			err = errors.New(diags.Errors()[0].Detail())
+			exec.FailVerification(err)
+			exitCode = exec.ExitCode
+		}
 	}
 
-	data.RunAt = types.StringValue(time.Now().Local().String())
+	resp.Diagnostics.Append(exec.Record(r.providerData)...)
+	resp.Diagnostics.Append(exec.Notify(ctx, r.providerData)...)
+	data.ExitCode = types.Int64Value(int64(exitCode))
+	data.RollbackExitCode = types.Int64Null()
+
+	if err != nil && exec != nil && !data.RollbackCommand.IsNull() {
+		rollback, rollbackErr := data.Rollback(ctx, r.providerData, runID, exec)
+		resp.Diagnostics.Append(rollback.Record(r.providerData)...)
+		data.RollbackExitCode = types.Int64Value(int64(util.ExitCode(rollbackErr)))
+
+		if rollbackErr != nil {
+			resp.Diagnostics.AddError("Rollback Command Error", fmt.Sprintf("Unable to roll back the failed command, got error: %s", rollbackErr))
+		} else {
+			resp.Diagnostics.AddWarning("Command Rolled Back", fmt.Sprintf("The command failed with exit code %d and rollback_command succeeded.", exec.ExitCode))
+		}
+	}
+
+	exitCodeEnv := "ONESHOT_EXIT_CODE=" + strconv.Itoa(exitCode)
+	_, diags = r.runHooks(ctx, data, runID, PhasePost, []string{exitCodeEnv}, postHooks...)
+
+	if err == nil {
+		// NOTE: Do not taint the resource for the failed post hooks, otherwise the succeeded command is executed again
+		for _, d := range diags {
+			resp.Diagnostics.AddWarning(d.Summary(), d.Detail())
+		}
+	} else {
+		resp.Diagnostics.Append(diags...)
+	}
+
+	data.Execution = exec.Object()
+
+	data.SetTimes(exec)
+	err = data.UploadLogs(r.providerData, runID)
+
+	if err != nil {
+		resp.Diagnostics.AddWarning("Log Sink Error", fmt.Sprintf("Unable to upload logs, got error: %s", err))
+	}
 
 	resp.Diagnostics.Append(resp.State.Set(ctx, &data)...)
+	resp.Diagnostics.Append(resp.Identity.Set(ctx, RunResourceIdentityModel{ID: data.ID})...)
+}
+
+// verify checks the output of the command and runs verify_command.
+func (r *RunResource) verify(ctx context.Context, data RunResourceModel, runID string, exec *Execution) diag.Diagnostics {
+	var diags diag.Diagnostics
+	err := data.CheckOutput(exec)
+
+	if err != nil {
+		diags.AddError("Verification Error", fmt.Sprintf("The command exited with zero but the output indicates an error: %s", err))
+		return diags
+	}
+
+	if data.VerifyCommand.IsNull() {
+		return diags
+	}
+
+	verifyExec, err := data.execute(ctx, r.providerData, runID, PhaseVerify, data.VerifyCommand.ValueString(), "", "", "ONESHOT_VERIFY=1")
+	diags.Append(verifyExec.Record(r.providerData)...)
+
+	if err != nil {
+		diags.AddError("Verification Error", fmt.Sprintf("The command exited with zero but verify_command failed, got error: %s", err))
+	}
+
+	return diags
+}
+
+// evaluateGuards evaluates creates, removes, only_if and unless in this order, and returns the reason to skip the command.
+// It returns null if the command should be executed.
+func (r *RunResource) evaluateGuards(ctx context.Context, data RunResourceModel, runID string) (types.String, diag.Diagnostics) {
+	var diags diag.Diagnostics
+
+	if !data.Creates.IsNull() {
+		_, err := os.Stat(data.workingPath(data.Creates.ValueString()))
+
+		if err == nil {
+			return types.StringValue(fmt.Sprintf("creates: %s exists", data.Creates.ValueString())), diags
+		}
+	}
+
+	if !data.Removes.IsNull() {
+		_, err := os.Stat(data.workingPath(data.Removes.ValueString()))
+
+		if errors.Is(err, fs.ErrNotExist) {
+			return types.StringValue(fmt.Sprintf("removes: %s does not exist", data.Removes.ValueString())), diags
+		}
+	}
+
+	for _, guard := range []struct {
+		name    string
+		command types.String
+		skip    func(exitCode int) bool
+	}{
+		{"only_if", data.OnlyIf, func(exitCode int) bool { return exitCode != 0 }},
+		{"unless", data.Unless, func(exitCode int) bool { return exitCode == 0 }},
+	} {
+		if guard.command.IsNull() {
+			continue
+		}
+
+		exec, err := data.execute(ctx, r.providerData, runID, PhaseGuard, guard.command.ValueString(), "", "", "ONESHOT_GUARD=1")
+		diags.Append(exec.Record(r.providerData)...)
+		exitCode := util.ExitCode(err)
+
+		if exitCode < 0 {
+			diags.AddError("Guard Command Error", fmt.Sprintf("Unable to run %s, got error: %s", guard.name, err))
+			return types.StringNull(), diags
+		}
+
+		if guard.skip(exitCode) {
+			return types.StringValue(fmt.Sprintf("%s: exited with code %d", guard.name, exitCode)), diags
+		}
+	}
+
+	return types.StringNull(), diags
+}
+
+// setPlannedSkip sets skip_reason and skipped of the plan.
+// If the command is not skipped by plan_command and the guard conditions are set,
+// they are unknown until the guard conditions are evaluated at apply time.
+func (r *RunResource) setPlannedSkip(ctx context.Context, data RunResourceModel, skipReason types.String, resp *resource.ModifyPlanResponse) {
+	skipped := types.BoolValue(!skipReason.IsNull())
+
+	if skipReason.IsNull() && data.HasGuards() {
+		skipReason = types.StringUnknown()
+		skipped = types.BoolUnknown()
+
+		if data.PreviewGuards.ValueBool() {
+			resp.Diagnostics.Append(r.previewGuards(ctx, data)...)
+		}
+	}
+
+	resp.Diagnostics.Append(resp.Plan.SetAttribute(ctx, path.Root("skip_reason"), skipReason)...)
+	resp.Diagnostics.Append(resp.Plan.SetAttribute(ctx, path.Root("skipped"), skipped)...)
+}
+
+// previewGuards evaluates the guard conditions at plan time.
+// The errors are reported as warnings because the guard conditions are evaluated again at apply time.
+func (r *RunResource) previewGuards(ctx context.Context, data RunResourceModel) diag.Diagnostics {
+	var diags diag.Diagnostics
+
+	for _, v := range []types.String{data.Creates, data.Removes, data.OnlyIf, data.Unless, data.Shell, data.WorkingDir} {
+		if v.IsUnknown() {
+			return diags
+		}
+	}
+
+	skipReason, guardDiags := r.evaluateGuards(ctx, data, uuid.NewString())
+
+	for _, d := range guardDiags {
+		diags.AddWarning(d.Summary(), d.Detail())
+	}
+
+	if !skipReason.IsNull() {
+		diags.AddWarning("Command Will Be Skipped", fmt.Sprintf("The command will be skipped at apply time unless the guard conditions change (%s).", skipReason.ValueString()))
+	}
+
+	return diags
+}
+
+// runHooks runs the hook commands in order and returns the number of the started hooks, including the failed one.
+// The pre hooks stop at the first failure, while the post hooks are all executed even if the command or the hooks failed.
+func (r *RunResource) runHooks(ctx context.Context, data RunResourceModel, runID string, phase string, extraEnvs []string, commands ...types.String) (int, diag.Diagnostics) {
+	var diags diag.Diagnostics
+	summary := "Pre Command Error"
+
+	if phase == PhasePost {
+		summary = "Post Command Error"
+	}
+
+	started := 0
+
+	for _, command := range commands {
+		started++
+
+		if command.IsNull() {
+			continue
+		}
+
+		exec, err := data.RunHook(ctx, r.providerData, runID, phase, command.ValueString(), extraEnvs...)
+		diags.Append(exec.Record(r.providerData)...)
+
+		if err != nil {
+			diags.AddError(summary, fmt.Sprintf("Unable to run %s command, got error: %s", phase, err))
+
+			if phase == PhasePre {
+				break
+			}
+		}
+	}
+
+	return started, diags
+}
+
+// verifyPlanFingerprint executes the plan command again and compares the fingerprint with the planned one
+// to prevent applying a stale plan.
+func (r *RunResource) verifyPlanFingerprint(ctx context.Context, data RunResourceModel, runID string) diag.Diagnostics {
+	var diags diag.Diagnostics
+	exec, err := data.Plan(ctx, r.providerData, runID)
+	diags.Append(exec.Record(r.providerData)...)
+	_, err = data.PlanSkipReason(err)
+
+	if err != nil {
+		diags.AddError("Plan Command Error", fmt.Sprintf("Unable to plan command, got error: %s", err))
+		return diags
+	}
+
+	if exec.Fingerprint != data.PlanFingerprint.ValueString() {
+		diags.AddError(
+			"Stale Plan",
+			fmt.Sprintf("The plan fingerprint has changed since the plan was created (planned: %q, current: %q). "+
+				"The command was not executed. Run terraform plan again.", data.PlanFingerprint.ValueString(), exec.Fingerprint),
+		)
+	}
+
+	return diags
 }
 
 func (r *RunResource) Read(ctx context.Context, req resource.ReadRequest, resp *resource.ReadResponse) {
-	// Nothing to do
+	var data RunResourceModel
+	resp.Diagnostics.Append(req.State.Get(ctx, &data)...)
+
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	// NOTE: Assign a run ID to the resources created before the ID was introduced
+	if data.ID.IsNull() {
+		data.ID = types.StringValue(uuid.NewString())
+		resp.Diagnostics.Append(resp.State.Set(ctx, &data)...)
+	}
+
+	if !data.CheckCommand.IsNull() {
+		exec, err := data.Check(ctx, r.providerData, data.ID.ValueString())
+		resp.Diagnostics.Append(exec.Record(r.providerData)...)
+		exitCode := util.ExitCode(err)
+
+		if data.Drifted(exitCode) {
+			resp.Diagnostics.AddWarning(
+				"Resource Drift Detected",
+				fmt.Sprintf("check_command exited with code %d, so the resource is removed from the state and the command will be executed again.", exitCode),
+			)
+
+			resp.State.RemoveResource(ctx)
+			return
+		} else if err != nil {
+			resp.Diagnostics.AddError("Check Command Error", fmt.Sprintf("Unable to check command, got error: %s", err))
+			return
+		}
+	}
+
+	resp.Diagnostics.Append(resp.Identity.Set(ctx, RunResourceIdentityModel{ID: data.ID})...)
 }
 
 func (r *RunResource) Update(ctx context.Context, req resource.UpdateRequest, resp *resource.UpdateResponse) {
-	// Nothing to do
+	var data, state RunResourceModel
+	resp.Diagnostics.Append(req.Plan.Get(ctx, &data)...)
+	resp.Diagnostics.Append(req.State.Get(ctx, &state)...)
+
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	// NOTE: The moved (or imported by identity) resource does not have the command yet,
+	// so the update command is not executed until the command is set from the configuration
+	if !state.Command.IsNull() && data.UpdateRequired(state) {
+		exec, err := data.RunUpdate(ctx, r.providerData, data.ID.ValueString(), state)
+		resp.Diagnostics.Append(exec.Record(r.providerData)...)
+		resp.Diagnostics.Append(exec.Notify(ctx, r.providerData)...)
+
+		if err != nil {
+			// NOTE: Keep the state so that the update command is executed again
+			resp.Diagnostics.AddError("Update Command Error", fmt.Sprintf("Unable to run update command, got error: %s", err))
+			return
+		}
+	}
+
+	// NOTE: Do not run command, only keep the changed settings
+	data.StartedAt = state.StartedAt
+	data.FinishedAt = state.FinishedAt
+	data.Duration = state.Duration
+	data.StdoutLogURL = state.StdoutLogURL
+	data.StderrLogURL = state.StderrLogURL
+	data.Execution = state.Execution
+	data.PlanOutput = state.PlanOutput
+	data.SkipReason = state.SkipReason
+	data.PlanFingerprint = state.PlanFingerprint
+	data.ExitCode = state.ExitCode
+	data.RollbackExitCode = state.RollbackExitCode
+	data.Skipped = state.Skipped
+	resp.Diagnostics.Append(resp.State.Set(ctx, &data)...)
 }
 
 func (r *RunResource) Delete(ctx context.Context, req resource.DeleteRequest, resp *resource.DeleteResponse) {
@@ -230,17 +1194,81 @@ func (r *RunResource) ModifyPlan(ctx context.Context, req resource.ModifyPlanReq
 	}
 
 	if !req.State.Raw.IsNull() {
-		// NOTE: Do not run plan command after creating tfstate
+		// NOTE: Do not run plan command after creating tfstate.
+		// When the resource is replaced (e.g. triggers are changed), Terraform plans the creation again with the null state,
+		// so the plan command is executed only once in that planning.
 		return
 	}
 
 	if data.PlanCommand.IsNull() {
+		resp.Diagnostics.Append(resp.Plan.SetAttribute(ctx, path.Root("plan_output"), types.StringNull())...)
+		resp.Diagnostics.Append(resp.Plan.SetAttribute(ctx, path.Root("plan_fingerprint"), types.StringNull())...)
+		r.setPlannedSkip(ctx, data, types.StringNull(), resp)
 		return
 	}
 
-	err := data.Plan(r.defaultShell)
+	if unknowns := data.UnknownPlanAttributes(); len(unknowns) > 0 {
+		// NOTE: Terraform plans the resource again with the known values before applying it,
+		// so the plan command is executed in that planning
+		if req.ClientCapabilities.DeferralAllowed {
+			resp.Deferred = &resource.Deferred{Reason: resource.DeferredReasonResourceConfigUnknown}
+			resp.Diagnostics.AddWarning(
+				"Plan Command Deferred",
+				fmt.Sprintf("The resource is deferred because %s is unknown at plan time.", strings.Join(unknowns, ", ")),
+			)
+		} else {
+			resp.Diagnostics.AddWarning(
+				"Plan Command Skipped",
+				fmt.Sprintf("plan_command is not executed because %s is unknown at plan time. It will be executed when the values are known at apply time.", strings.Join(unknowns, ", ")),
+			)
+		}
+
+		return
+	}
+
+	exec, err := data.Plan(ctx, r.providerData, uuid.NewString())
+	skipReason, err := data.PlanSkipReason(err)
 
 	if err != nil {
 		resp.Diagnostics.AddError("Plan Command Error", fmt.Sprintf("Unable to plan command, got error: %s", err))
 	}
+
+	resp.Diagnostics.Append(exec.Record(r.providerData)...)
+
+	if err != nil {
+		return
+	}
+
+	planOutput := types.StringNull()
+
+	if data.RecordPlanOutput.ValueBool() {
+		planOutput = types.StringValue(exec.Stdout)
+	}
+
+	resp.Diagnostics.Append(resp.Plan.SetAttribute(ctx, path.Root("plan_output"), planOutput)...)
+	r.setPlannedSkip(ctx, data, skipReason, resp)
+	fingerprint := types.StringNull()
+
+	// NOTE: The plan command is executed again when Terraform plans the resource at apply time.
+	// If the fingerprint has changed since the saved plan, Terraform rejects the plan as inconsistent.
+	if exec.Fingerprint != "" {
+		fingerprint = types.StringValue(exec.Fingerprint)
+	}
+
+	resp.Diagnostics.Append(resp.Plan.SetAttribute(ctx, path.Root("plan_fingerprint"), fingerprint)...)
+
+	if data.WarnPlanOutput.ValueBool() {
+		resp.Diagnostics.AddWarning("Plan Command Output", truncate(exec.Stdout, MaxPlanOutputWarningLength))
+	}
+}
+
+// truncate truncates the string to the maximum number of characters.
+func truncate(s string, max int) string {
+	runes := []rune(s)
+
+	if len(runes) <= max {
+		return s
+	}
+
+	return string(runes[:max]) + fmt.Sprintf("\n... (truncated %d characters)", len(runes)-max)
 }
@@ -0,0 +1,230 @@
+package provider
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"net/url"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/hashicorp/terraform-plugin-framework/attr"
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/tfsdk"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+)
+
+var _ resource.ResourceWithImportState = &RunResource{}
+
+// runImportID is the import ID, which has the attributes of the already executed run.
+type runImportID struct {
+	ID             string            `json:"id"`
+	Command        string            `json:"command"`
+	PlanCommand    string            `json:"plan_command"`
+	Shell          string            `json:"shell"`
+	WorkingDir     string            `json:"working_dir"`
+	StdoutLog      string            `json:"stdout_log"`
+	StderrLog      string            `json:"stderr_log"`
+	PlanStdoutLog  string            `json:"plan_stdout_log"`
+	PlanStderrLog  string            `json:"plan_stderr_log"`
+	MetricsLabel   string            `json:"metrics_label"`
+	Triggers       map[string]string `json:"triggers"`
+	Environment    map[string]string `json:"environment"`
+	UpdateTriggers map[string]string `json:"update_triggers"`
+}
+
+// parseImportID parses the import ID in JSON or comma-separated key=value format.
+// e.g. `{"command":"./migrate.sh","triggers":{"version":"1"}}` or `command=./migrate.sh,triggers.version=1`
+// The map attributes (triggers, environment and update_triggers) are specified with the prefix of the name in key=value format.
+// Values in key=value format can be percent-encoded. e.g. `command=echo%2C%20hello`
+func parseImportID(id string) (*runImportID, error) {
+	var importID runImportID
+
+	if strings.HasPrefix(strings.TrimSpace(id), "{") {
+		dec := json.NewDecoder(bytes.NewReader([]byte(id)))
+		dec.DisallowUnknownFields()
+		err := dec.Decode(&importID)
+
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse import ID as JSON: %w", err)
+		}
+	} else {
+		fields := map[string]*string{
+			"id":              &importID.ID,
+			"command":         &importID.Command,
+			"plan_command":    &importID.PlanCommand,
+			"shell":           &importID.Shell,
+			"working_dir":     &importID.WorkingDir,
+			"stdout_log":      &importID.StdoutLog,
+			"stderr_log":      &importID.StderrLog,
+			"plan_stdout_log": &importID.PlanStdoutLog,
+			"plan_stderr_log": &importID.PlanStderrLog,
+			"metrics_label":   &importID.MetricsLabel,
+		}
+
+		for _, kv := range strings.Split(id, ",") {
+			k, v, ok := strings.Cut(kv, "=")
+
+			if !ok {
+				return nil, fmt.Errorf("invalid key=value in import ID: %s", kv)
+			}
+
+			v, err := url.PathUnescape(v)
+
+			if err != nil {
+				return nil, fmt.Errorf("invalid value of %s in import ID: %w", k, err)
+			}
+
+			k = strings.TrimSpace(k)
+
+			if name, m, ok := importID.mapField(k); ok {
+				if *m == nil {
+					*m = map[string]string{}
+				}
+
+				(*m)[name] = v
+			} else if field, ok := fields[k]; ok {
+				*field = v
+			} else {
+				return nil, fmt.Errorf("unknown key in import ID: %s", k)
+			}
+		}
+	}
+
+	if importID.Command == "" {
+		return nil, errors.New("command is required in import ID")
+	}
+
+	return &importID, nil
+}
+
+// mapField returns the map attribute and the name of its element for the key in key=value format.
+// e.g. `triggers.version` returns ("version", &importID.Triggers, true)
+func (importID *runImportID) mapField(k string) (string, *map[string]string, bool) {
+	for prefix, m := range map[string]*map[string]string{
+		"triggers.":        &importID.Triggers,
+		"environment.":     &importID.Environment,
+		"update_triggers.": &importID.UpdateTriggers,
+	} {
+		if name, ok := strings.CutPrefix(k, prefix); ok {
+			return name, m, true
+		}
+	}
+
+	return "", nil, false
+}
+
+func (r *RunResource) ImportState(ctx context.Context, req resource.ImportStateRequest, resp *resource.ImportStateResponse) {
+	if req.ID == "" {
+		importRunStateByIdentity(ctx, req, resp)
+		return
+	}
+
+	importID, err := parseImportID(req.ID)
+
+	if err != nil {
+		resp.Diagnostics.AddError("Invalid Import ID", err.Error())
+		return
+	}
+
+	optional := func(s string) types.String {
+		if s == "" {
+			return types.StringNull()
+		}
+
+		return types.StringValue(s)
+	}
+
+	if importID.ID == "" {
+		importID.ID = uuid.NewString()
+	}
+
+	// NOTE: The command is not executed, the existence of the state means that the command has already been executed
+	attrs := map[string]attr.Value{
+		"id":            types.StringValue(importID.ID),
+		"command":       types.StringValue(importID.Command),
+		"plan_command":  optional(importID.PlanCommand),
+		"shell":         optional(importID.Shell),
+		"working_dir":   optional(importID.WorkingDir),
+		"metrics_label": optional(importID.MetricsLabel),
+	}
+
+	for name, v := range map[string]string{
+		"stdout_log":      importID.StdoutLog,
+		"stderr_log":      importID.StderrLog,
+		"plan_stdout_log": importID.PlanStdoutLog,
+		"plan_stderr_log": importID.PlanStderrLog,
+	} {
+		if v != "" {
+			attrs[name] = types.StringValue(v)
+		}
+	}
+
+	// NOTE: environment and update_triggers are also imported so that update_command is not executed by the next apply
+	for name, m := range map[string]map[string]string{
+		"triggers":        importID.Triggers,
+		"environment":     importID.Environment,
+		"update_triggers": importID.UpdateTriggers,
+	} {
+		if m != nil {
+			v, diags := types.MapValueFrom(ctx, types.StringType, m)
+			resp.Diagnostics.Append(diags...)
+			attrs[name] = v
+		}
+	}
+
+	resp.Diagnostics.Append(setExecutedState(ctx, &resp.State, attrs)...)
+}
+
+// importRunStateByIdentity imports the run by the resource identity.
+// Since the identity does not have the command, it is set from the configuration by the next apply without running it,
+// in the same way as the moved resource. See requiresReplaceUnlessMoved.
+func importRunStateByIdentity(ctx context.Context, req resource.ImportStateRequest, resp *resource.ImportStateResponse) {
+	var identity RunResourceIdentityModel
+
+	if req.Identity != nil {
+		resp.Diagnostics.Append(req.Identity.Get(ctx, &identity)...)
+
+		if resp.Diagnostics.HasError() {
+			return
+		}
+	}
+
+	if identity.ID.ValueString() == "" {
+		resp.Diagnostics.AddError("Invalid Import Identity", "id is required in the identity.")
+		return
+	}
+
+	resp.Diagnostics.Append(setExecutedState(ctx, &resp.State, map[string]attr.Value{"id": identity.ID})...)
+
+	if resp.Identity != nil {
+		resp.Diagnostics.Append(resp.Identity.Set(ctx, identity)...)
+	}
+}
+
+// setExecutedState sets the state of the run that has already been executed outside of Terraform.
+// The attributes not in attrs are null, except the defaults of the log files.
+func setExecutedState(ctx context.Context, state *tfsdk.State, attrs map[string]attr.Value) diag.Diagnostics {
+	var diags diag.Diagnostics
+
+	values := map[string]attr.Value{
+		"stdout_log":      types.StringValue("stdout.log"),
+		"stderr_log":      types.StringValue("stderr.log"),
+		"plan_stdout_log": types.StringValue("stdout.log"),
+		"plan_stderr_log": types.StringValue("stderr.log"),
+		"skipped":         types.BoolValue(false),
+	}
+
+	maps.Copy(values, attrs)
+
+	for name, v := range values {
+		diags.Append(state.SetAttribute(ctx, path.Root(name), v)...)
+	}
+
+	return diags
+}
@@ -0,0 +1,294 @@
+package provider_test
+
+import (
+	"context"
+	"os"
+	"regexp"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/tfsdk"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/hashicorp/terraform-plugin-go/tftypes"
+	helper "github.com/hashicorp/terraform-plugin-testing/helper/resource"
+	"github.com/hashicorp/terraform-plugin-testing/plancheck"
+	"github.com/hashicorp/terraform-plugin-testing/terraform"
+	"github.com/hashicorp/terraform-plugin-testing/tfversion"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/winebarrel/terraform-provider-oneshot/internal/provider"
+)
+
+func importRunState(t *testing.T, id string) (*provider.RunResourceModel, diag.Diagnostics) {
+	ctx := context.Background()
+	r := provider.NewRunResource().(resource.ResourceWithImportState)
+	schemaResp := &resource.SchemaResponse{}
+	r.Schema(ctx, resource.SchemaRequest{}, schemaResp)
+
+	resp := &resource.ImportStateResponse{
+		State: tfsdk.State{
+			Schema: schemaResp.Schema,
+			Raw:    tftypes.NewValue(schemaResp.Schema.Type().TerraformType(ctx), nil),
+		},
+	}
+
+	r.ImportState(ctx, resource.ImportStateRequest{ID: id}, resp)
+
+	if resp.Diagnostics.HasError() {
+		return nil, resp.Diagnostics
+	}
+
+	var data provider.RunResourceModel
+	resp.Diagnostics.Append(resp.State.Get(ctx, &data)...)
+	require.False(t, resp.Diagnostics.HasError(), resp.Diagnostics)
+
+	return &data, resp.Diagnostics
+}
+
+func TestRunImportState_JSON(t *testing.T) {
+	assert := assert.New(t)
+
+	data, _ := importRunState(t, `{"command": "./migrate.sh", "plan_command": "./migrate.sh --dry-run", "triggers": {"version": "1"}}`)
+
+	assert.Regexp(`^[0-9a-f-]{36}$`, data.ID.ValueString())
+	assert.Equal("./migrate.sh", data.Command.ValueString())
+	assert.Equal("./migrate.sh --dry-run", data.PlanCommand.ValueString())
+	assert.True(data.Shell.IsNull())
+	assert.Equal("stdout.log", data.StdoutLog.ValueString())
+	assert.Equal("stderr.log", data.StderrLog.ValueString())
+	assert.Equal(`{"version":"1"}`, data.Triggers.String())
+	assert.True(data.StartedAt.IsNull())
+	assert.True(data.Execution.IsNull())
+	assert.True(data.PlanOutput.IsNull())
+	assert.Equal(types.BoolValue(false), data.Skipped)
+}
+
+func TestRunImportState_KeyValue(t *testing.T) {
+	assert := assert.New(t)
+
+	data, _ := importRunState(t, "id=d6e1ff3c-5d0e-4d5e-9f4c-2c1c8f3b1a00,command=echo%2C hello,shell=/bin/sh -c,triggers.version=1,triggers.env=prod,environment.FOO=bar,update_triggers.schema=v2")
+
+	assert.Equal("d6e1ff3c-5d0e-4d5e-9f4c-2c1c8f3b1a00", data.ID.ValueString())
+	assert.Equal("echo, hello", data.Command.ValueString())
+	assert.Equal("/bin/sh -c", data.Shell.ValueString())
+	assert.True(data.PlanCommand.IsNull())
+	assert.Equal(`{"env":"prod","version":"1"}`, data.Triggers.String())
+	assert.Equal(`{"FOO":"bar"}`, data.Environment.String())
+	assert.Equal(`{"schema":"v2"}`, data.UpdateTriggers.String())
+}
+
+func TestRunImportState_Identity(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+	ctx := context.Background()
+	r := provider.NewRunResource().(resource.ResourceWithIdentity)
+	schemaResp := &resource.SchemaResponse{}
+	r.Schema(ctx, resource.SchemaRequest{}, schemaResp)
+	identitySchemaResp := &resource.IdentitySchemaResponse{}
+	r.IdentitySchema(ctx, resource.IdentitySchemaRequest{}, identitySchemaResp)
+	identityType := identitySchemaResp.IdentitySchema.Type().TerraformType(ctx)
+
+	req := resource.ImportStateRequest{
+		Identity: &tfsdk.ResourceIdentity{
+			Schema: identitySchemaResp.IdentitySchema,
+			Raw: tftypes.NewValue(identityType, map[string]tftypes.Value{
+				"id": tftypes.NewValue(tftypes.String, "d6e1ff3c-5d0e-4d5e-9f4c-2c1c8f3b1a00"),
+			}),
+		},
+	}
+
+	resp := &resource.ImportStateResponse{
+		State: tfsdk.State{
+			Schema: schemaResp.Schema,
+			Raw:    tftypes.NewValue(schemaResp.Schema.Type().TerraformType(ctx), nil),
+		},
+		Identity: &tfsdk.ResourceIdentity{
+			Schema: identitySchemaResp.IdentitySchema,
+			Raw:    tftypes.NewValue(identityType, nil),
+		},
+	}
+
+	r.(resource.ResourceWithImportState).ImportState(ctx, req, resp)
+	require.False(resp.Diagnostics.HasError(), resp.Diagnostics)
+
+	var data provider.RunResourceModel
+	resp.Diagnostics.Append(resp.State.Get(ctx, &data)...)
+	require.False(resp.Diagnostics.HasError(), resp.Diagnostics)
+
+	assert.Equal("d6e1ff3c-5d0e-4d5e-9f4c-2c1c8f3b1a00", data.ID.ValueString())
+	assert.True(data.Command.IsNull())
+	assert.True(data.Triggers.IsNull())
+	assert.Equal("stdout.log", data.StdoutLog.ValueString())
+
+	var identity provider.RunResourceIdentityModel
+	resp.Diagnostics.Append(resp.Identity.Get(ctx, &identity)...)
+	assert.Equal("d6e1ff3c-5d0e-4d5e-9f4c-2c1c8f3b1a00", identity.ID.ValueString())
+}
+
+func TestRunImportState_Err(t *testing.T) {
+	assert := assert.New(t)
+
+	for id, msg := range map[string]string{
+		"":                                  "id is required in the identity",
+		`{"command": "echo", "foo": "bar"}`: `unknown field "foo"`,
+		`{"triggers": {"version": "1"}}`:    "command is required in import ID",
+		"command=echo,foo=bar":              "unknown key in import ID: foo",
+		"command=echo,shell":                "invalid key=value in import ID: shell",
+		"command=%zz":                       "invalid value of command in import ID",
+	} {
+		_, diags := importRunState(t, id)
+		assert.True(diags.HasError(), id)
+		assert.Contains(diags.Errors()[0].Detail(), msg, id)
+	}
+}
+
+func TestRun_ImportBlock(t *testing.T) {
+	assert := assert.New(t)
+
+	cwd, _ := os.Getwd()
+	os.Chdir(t.TempDir())
+	defer os.Chdir(cwd)
+
+	helper.Test(t, helper.TestCase{
+		IsUnitTest:               true,
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		TerraformVersionChecks: []tfversion.TerraformVersionCheck{
+			tfversion.SkipBelow(tfversion.Version1_5_0),
+		},
+		Steps: []helper.TestStep{
+			{
+				Config: `
+					resource "oneshot_run" "json" {
+						command  = "echo json > json.txt"
+						triggers = { version = "1" }
+					}
+
+					import {
+						to = oneshot_run.json
+						id = jsonencode({ command = "echo json > json.txt", triggers = { version = "1" } })
+					}
+
+					resource "oneshot_run" "kv" {
+						command = "echo kv > kv.txt"
+						shell   = "/bin/sh -c"
+					}
+
+					import {
+						to = oneshot_run.kv
+						id = "command=echo kv > kv.txt,shell=/bin/sh -c"
+					}
+				`,
+				ConfigPlanChecks: helper.ConfigPlanChecks{
+					PreApply: []plancheck.PlanCheck{
+						plancheck.ExpectResourceAction("oneshot_run.json", plancheck.ResourceActionNoop),
+						plancheck.ExpectResourceAction("oneshot_run.kv", plancheck.ResourceActionNoop),
+					},
+				},
+				Check: helper.ComposeTestCheckFunc(
+					helper.TestMatchResourceAttr("oneshot_run.json", "id", regexp.MustCompile(`^[0-9a-f-]{36}$`)),
+					helper.TestCheckNoResourceAttr("oneshot_run.json", "started_at"),
+					func(_ *terraform.State) error {
+						assert.NoFileExists("json.txt")
+						assert.NoFileExists("kv.txt")
+						return nil
+					},
+				),
+			},
+		},
+	})
+}
+
+func TestRun_ImportBlockIdentity(t *testing.T) {
+	assert := assert.New(t)
+
+	cwd, _ := os.Getwd()
+	os.Chdir(t.TempDir())
+	defer os.Chdir(cwd)
+
+	helper.Test(t, helper.TestCase{
+		IsUnitTest:               true,
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		TerraformVersionChecks: []tfversion.TerraformVersionCheck{
+			tfversion.SkipBelow(tfversion.Version1_12_0),
+		},
+		Steps: []helper.TestStep{
+			{
+				Config: `
+					resource "oneshot_run" "identity" {
+						command  = "echo identity > identity.txt"
+						triggers = { version = "1" }
+					}
+
+					import {
+						to       = oneshot_run.identity
+						identity = { id = "d6e1ff3c-5d0e-4d5e-9f4c-2c1c8f3b1a00" }
+					}
+				`,
+				ConfigPlanChecks: helper.ConfigPlanChecks{
+					PreApply: []plancheck.PlanCheck{
+						plancheck.ExpectResourceAction("oneshot_run.identity", plancheck.ResourceActionUpdate),
+					},
+				},
+				Check: helper.ComposeTestCheckFunc(
+					helper.TestCheckResourceAttr("oneshot_run.identity", "id", "d6e1ff3c-5d0e-4d5e-9f4c-2c1c8f3b1a00"),
+					helper.TestCheckResourceAttr("oneshot_run.identity", "command", "echo identity > identity.txt"),
+					helper.TestCheckResourceAttr("oneshot_run.identity", "triggers.version", "1"),
+					helper.TestCheckNoResourceAttr("oneshot_run.identity", "started_at"),
+					func(_ *terraform.State) error {
+						assert.NoFileExists("identity.txt")
+						return nil
+					},
+				),
+			},
+		},
+	})
+}
+
+func TestRun_ImportBlockUpdateCommand(t *testing.T) {
+	assert := assert.New(t)
+
+	cwd, _ := os.Getwd()
+	os.Chdir(t.TempDir())
+	defer os.Chdir(cwd)
+
+	helper.Test(t, helper.TestCase{
+		IsUnitTest:               true,
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		TerraformVersionChecks: []tfversion.TerraformVersionCheck{
+			tfversion.SkipBelow(tfversion.Version1_5_0),
+		},
+		Steps: []helper.TestStep{
+			{
+				// update_command is not executed because environment and update_triggers are imported
+				Config: `
+					resource "oneshot_run" "migrate" {
+						command         = "echo migrate > migrate.txt"
+						update_command  = "echo update > update.txt"
+						environment     = { FOO = "bar" }
+						update_triggers = { schema = "v2" }
+					}
+
+					import {
+						to = oneshot_run.migrate
+						id = jsonencode({
+							command         = "echo migrate > migrate.txt"
+							environment     = { FOO = "bar" }
+							update_triggers = { schema = "v2" }
+						})
+					}
+				`,
+				ConfigPlanChecks: helper.ConfigPlanChecks{
+					PreApply: []plancheck.PlanCheck{
+						plancheck.ExpectResourceAction("oneshot_run.migrate", plancheck.ResourceActionNoop),
+					},
+				},
+				Check: func(_ *terraform.State) error {
+					assert.NoFileExists("migrate.txt")
+					assert.NoFileExists("update.txt")
+					return nil
+				},
+			},
+		},
+	})
+}
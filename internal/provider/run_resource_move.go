@@ -0,0 +1,175 @@
+package provider
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/hashicorp/terraform-plugin-framework/attr"
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/mapplanmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/planmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/stringplanmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+)
+
+var _ resource.ResourceWithMoveState = &RunResource{}
+
+const (
+	nullProviderAddress      = "registry.terraform.io/hashicorp/null"
+	terraformProviderAddress = "terraform.io/builtin/terraform"
+)
+
+func (r *RunResource) MoveState(ctx context.Context) []resource.StateMover {
+	return []resource.StateMover{
+		// NOTE: The raw state is used because the source schemas belong to other providers
+		{StateMover: moveRunResourceState},
+	}
+}
+
+// moveRunResourceState moves null_resource and terraform_data to oneshot_run.
+// The command is regarded as already executed. Since the source state does not have the command,
+// it is set from the configuration by the next apply without running it. See requiresReplaceUnlessMoved.
+func moveRunResourceState(ctx context.Context, req resource.MoveStateRequest, resp *resource.MoveStateResponse) {
+	if req.SourceRawState == nil {
+		return
+	}
+
+	var triggersKey string
+
+	switch {
+	case req.SourceTypeName == "null_resource" && req.SourceProviderAddress == nullProviderAddress:
+		triggersKey = "triggers"
+	case req.SourceTypeName == "terraform_data" && req.SourceProviderAddress == terraformProviderAddress:
+		triggersKey = "triggers_replace"
+	default:
+		return
+	}
+
+	var source map[string]any
+	dec := json.NewDecoder(bytes.NewReader(req.SourceRawState.JSON))
+	dec.UseNumber()
+	err := dec.Decode(&source)
+
+	if err != nil {
+		resp.Diagnostics.AddError("Unable to Move Resource State", fmt.Sprintf("Unable to parse %s state, got error: %s", req.SourceTypeName, err))
+		return
+	}
+
+	triggers, err := movedTriggers(triggersKey, source[triggersKey])
+
+	if err != nil {
+		resp.Diagnostics.AddError("Unable to Move Resource State", fmt.Sprintf("Unable to convert %s, got error: %s", triggersKey, err))
+		return
+	}
+
+	id := uuid.NewString()
+
+	// terraform_data has a UUID
+	if sourceID, ok := source["id"].(string); ok && triggersKey == "triggers_replace" {
+		if _, err := uuid.Parse(sourceID); err == nil {
+			id = sourceID
+		}
+	}
+
+	// NOTE: command is null until it is set from the configuration
+	attrs := map[string]attr.Value{
+		"id": types.StringValue(id),
+	}
+
+	if triggers != nil {
+		v, diags := types.MapValueFrom(ctx, types.StringType, triggers)
+		resp.Diagnostics.Append(diags...)
+		attrs["triggers"] = v
+	}
+
+	resp.Diagnostics.Append(setExecutedState(ctx, &resp.TargetState, attrs)...)
+
+	if resp.TargetIdentity != nil {
+		resp.Diagnostics.Append(resp.TargetIdentity.Set(ctx, RunResourceIdentityModel{ID: types.StringValue(id)})...)
+	}
+}
+
+// movedTriggers converts the triggers of the source resource to the map of strings.
+// A map or object of primitive values is kept as is, and other values are encoded in JSON as one trigger.
+func movedTriggers(key string, v any) (map[string]string, error) {
+	// triggers_replace is a dynamic value, which is stored with its type
+	if m, ok := v.(map[string]any); ok && key == "triggers_replace" && len(m) == 2 && m["type"] != nil {
+		if value, ok := m["value"]; ok {
+			v = value
+		}
+	}
+
+	if v == nil {
+		return nil, nil
+	}
+
+	if m, ok := v.(map[string]any); ok {
+		if triggers, ok := stringMap(m); ok {
+			return triggers, nil
+		}
+	}
+
+	if s, ok := v.(string); ok {
+		return map[string]string{key: s}, nil
+	}
+
+	b, err := json.Marshal(v)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return map[string]string{key: string(b)}, nil
+}
+
+// stringMap converts the map to the map of strings if all values are primitive.
+func stringMap(m map[string]any) (map[string]string, bool) {
+	strs := map[string]string{}
+
+	for k, v := range m {
+		switch v := v.(type) {
+		case string:
+			strs[k] = v
+		case json.Number:
+			strs[k] = v.String()
+		case bool:
+			strs[k] = fmt.Sprint(v)
+		default:
+			return nil, false
+		}
+	}
+
+	return strs, true
+}
+
+// requiresReplaceUnlessMoved requires replacement if the value is changed,
+// except when the resource has been moved from another resource type (or imported by identity) and does not have the command yet.
+func requiresReplaceUnlessMoved() planmodifier.String {
+	return stringplanmodifier.RequiresReplaceIf(
+		func(ctx context.Context, req planmodifier.StringRequest, resp *stringplanmodifier.RequiresReplaceIfFuncResponse) {
+			var command types.String
+			resp.Diagnostics.Append(req.State.GetAttribute(ctx, path.Root("command"), &command)...)
+			resp.RequiresReplace = !command.IsNull()
+		},
+		"If the value of this attribute changes, Terraform will destroy and recreate the resource.",
+		"If the value of this attribute changes, Terraform will destroy and recreate the resource.",
+	)
+}
+
+// requiresReplaceMapUnlessMoved is requiresReplaceUnlessMoved for the map attributes.
+// The value carried over from the source resource (e.g. triggers of null_resource) still requires replacement if it is changed.
+func requiresReplaceMapUnlessMoved() planmodifier.Map {
+	return mapplanmodifier.RequiresReplaceIf(
+		func(ctx context.Context, req planmodifier.MapRequest, resp *mapplanmodifier.RequiresReplaceIfFuncResponse) {
+			var command types.String
+			resp.Diagnostics.Append(req.State.GetAttribute(ctx, path.Root("command"), &command)...)
+			resp.RequiresReplace = !command.IsNull() || !req.StateValue.IsNull()
+		},
+		"If the value of this attribute changes, Terraform will destroy and recreate the resource.",
+		"If the value of this attribute changes, Terraform will destroy and recreate the resource.",
+	)
+}
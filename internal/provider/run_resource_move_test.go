@@ -0,0 +1,167 @@
+package provider_test
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/tfsdk"
+	"github.com/hashicorp/terraform-plugin-go/tfprotov6"
+	"github.com/hashicorp/terraform-plugin-go/tftypes"
+	helper "github.com/hashicorp/terraform-plugin-testing/helper/resource"
+	"github.com/hashicorp/terraform-plugin-testing/plancheck"
+	"github.com/hashicorp/terraform-plugin-testing/terraform"
+	"github.com/hashicorp/terraform-plugin-testing/tfversion"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/winebarrel/terraform-provider-oneshot/internal/provider"
+)
+
+func moveRunState(t *testing.T, providerAddress string, typeName string, rawState string) (*provider.RunResourceModel, diag.Diagnostics) {
+	ctx := context.Background()
+	r := provider.NewRunResource().(resource.ResourceWithMoveState)
+	schemaResp := &resource.SchemaResponse{}
+	r.Schema(ctx, resource.SchemaRequest{}, schemaResp)
+
+	req := resource.MoveStateRequest{
+		SourceProviderAddress: providerAddress,
+		SourceTypeName:        typeName,
+		SourceRawState:        &tfprotov6.RawState{JSON: []byte(rawState)},
+	}
+
+	resp := &resource.MoveStateResponse{
+		TargetState: tfsdk.State{
+			Schema: schemaResp.Schema,
+			Raw:    tftypes.NewValue(schemaResp.Schema.Type().TerraformType(ctx), nil),
+		},
+	}
+
+	r.MoveState(ctx)[0].StateMover(ctx, req, resp)
+
+	if resp.Diagnostics.HasError() || resp.TargetState.Raw.IsNull() {
+		return nil, resp.Diagnostics
+	}
+
+	var data provider.RunResourceModel
+	resp.Diagnostics.Append(resp.TargetState.Get(ctx, &data)...)
+	require.False(t, resp.Diagnostics.HasError(), resp.Diagnostics)
+
+	return &data, resp.Diagnostics
+}
+
+func TestRunMoveState_NullResource(t *testing.T) {
+	assert := assert.New(t)
+
+	data, _ := moveRunState(t, "registry.terraform.io/hashicorp/null", "null_resource", `{"id": "1234567890", "triggers": {"version": "1"}}`)
+
+	assert.Regexp(`^[0-9a-f-]{36}$`, data.ID.ValueString())
+	assert.True(data.Command.IsNull())
+	assert.Equal(`{"version":"1"}`, data.Triggers.String())
+	assert.Equal("stdout.log", data.StdoutLog.ValueString())
+
+	data, _ = moveRunState(t, "registry.terraform.io/hashicorp/null", "null_resource", `{"id": "1234567890", "triggers": null}`)
+	assert.True(data.Triggers.IsNull())
+}
+
+func TestRunMoveState_TerraformData(t *testing.T) {
+	assert := assert.New(t)
+
+	data, _ := moveRunState(t, "terraform.io/builtin/terraform", "terraform_data", `{
+		"id": "d6e1ff3c-5d0e-4d5e-9f4c-2c1c8f3b1a00",
+		"input": null,
+		"output": null,
+		"triggers_replace": {"value": {"version": "1", "count": 2, "enabled": true}, "type": ["object", {"version": "string", "count": "number", "enabled": "bool"}]}
+	}`)
+
+	assert.Equal("d6e1ff3c-5d0e-4d5e-9f4c-2c1c8f3b1a00", data.ID.ValueString())
+	assert.Equal(`{"count":"2","enabled":"true","version":"1"}`, data.Triggers.String())
+
+	for rawTriggers, expected := range map[string]string{
+		`{"value": "v1", "type": "string"}`:                              `{"triggers_replace":"v1"}`,
+		`{"value": ["a", "b"], "type": ["tuple", ["string", "string"]]}`: `{"triggers_replace":"[\"a\",\"b\"]"}`,
+		`null`: `<null>`,
+	} {
+		data, _ = moveRunState(t, "terraform.io/builtin/terraform", "terraform_data", `{"id": "x", "triggers_replace": `+rawTriggers+`}`)
+		assert.Equal(expected, data.Triggers.String(), rawTriggers)
+	}
+}
+
+func TestRunMoveState_Unsupported(t *testing.T) {
+	assert := assert.New(t)
+
+	data, diags := moveRunState(t, "registry.terraform.io/hashicorp/aws", "aws_instance", `{"id": "i-123"}`)
+	assert.Nil(data)
+	assert.False(diags.HasError())
+}
+
+func TestRun_MoveFromTerraformData(t *testing.T) {
+	assert := assert.New(t)
+
+	cwd, _ := os.Getwd()
+	os.Chdir(t.TempDir())
+	defer os.Chdir(cwd)
+
+	helper.Test(t, helper.TestCase{
+		IsUnitTest:               true,
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		TerraformVersionChecks: []tfversion.TerraformVersionCheck{
+			tfversion.SkipBelow(tfversion.Version1_8_0),
+		},
+		Steps: []helper.TestStep{
+			{
+				Config: `
+					resource "terraform_data" "hello" {
+						triggers_replace = { version = "1" }
+
+						provisioner "local-exec" {
+							command = "echo hello >> hello.txt"
+						}
+					}
+				`,
+			},
+			{
+				Config: `
+					resource "oneshot_run" "hello" {
+						command  = "echo hello >> hello.txt"
+						triggers = { version = "1" }
+					}
+
+					moved {
+						from = terraform_data.hello
+						to   = oneshot_run.hello
+					}
+				`,
+				ConfigPlanChecks: helper.ConfigPlanChecks{
+					PreApply: []plancheck.PlanCheck{
+						plancheck.ExpectResourceAction("oneshot_run.hello", plancheck.ResourceActionUpdate),
+					},
+				},
+				Check: helper.ComposeTestCheckFunc(
+					helper.TestCheckResourceAttr("oneshot_run.hello", "command", "echo hello >> hello.txt"),
+					helper.TestCheckResourceAttr("oneshot_run.hello", "triggers.version", "1"),
+					func(_ *terraform.State) error {
+						b, _ := os.ReadFile("hello.txt")
+						assert.Equal("hello\n", string(b))
+						return nil
+					},
+				),
+			},
+			{
+				// Changing the command after moving runs it
+				Config: `
+					resource "oneshot_run" "hello" {
+						command  = "echo world >> hello.txt"
+						triggers = { version = "1" }
+					}
+				`,
+				ConfigPlanChecks: helper.ConfigPlanChecks{
+					PreApply: []plancheck.PlanCheck{
+						plancheck.ExpectResourceAction("oneshot_run.hello", plancheck.ResourceActionReplace),
+					},
+				},
+			},
+		},
+	})
+}
@@ -1,15 +1,33 @@
 package provider_test
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"regexp"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
+	"filippo.io/age"
+	"github.com/hashicorp/terraform-plugin-framework/types"
 	"github.com/hashicorp/terraform-plugin-testing/helper/resource"
+	"github.com/hashicorp/terraform-plugin-testing/knownvalue"
 	"github.com/hashicorp/terraform-plugin-testing/plancheck"
+	"github.com/hashicorp/terraform-plugin-testing/statecheck"
 	"github.com/hashicorp/terraform-plugin-testing/terraform"
+	"github.com/hashicorp/terraform-plugin-testing/tfjsonpath"
+	"github.com/hashicorp/terraform-plugin-testing/tfversion"
 	"github.com/stretchr/testify/assert"
+	"github.com/winebarrel/terraform-provider-oneshot/internal/provider"
+	"github.com/winebarrel/terraform-provider-oneshot/internal/util"
 )
 
 func TestRun_Basic(t *testing.T) {
@@ -40,7 +58,9 @@ func TestRun_Basic(t *testing.T) {
 					resource.TestCheckResourceAttr("oneshot_run.hello", "stderr_log", "stderr.log"),
 					resource.TestCheckResourceAttr("oneshot_run.hello", "plan_stdout_log", "plan-stdout.log"),
 					resource.TestCheckResourceAttr("oneshot_run.hello", "plan_stderr_log", "plan-stderr.log"),
-					resource.TestMatchResourceAttr("oneshot_run.hello", "run_at", regexp.MustCompile(`^\d{4}-\d{2}-\d{2} \d{2}:\d{2}:\d{2}\.\d+`)),
+					resource.TestMatchResourceAttr("oneshot_run.hello", "started_at", regexp.MustCompile(`^\d{4}-\d{2}-\d{2}T\d{2}:\d{2}:\d{2}(Z|[+-]\d{2}:\d{2})$`)),
+					resource.TestMatchResourceAttr("oneshot_run.hello", "finished_at", regexp.MustCompile(`^\d{4}-\d{2}-\d{2}T\d{2}:\d{2}:\d{2}(Z|[+-]\d{2}:\d{2})$`)),
+					resource.TestMatchResourceAttr("oneshot_run.hello", "duration", regexp.MustCompile(`^[\d.]+(ns|µs|ms|s)$`)),
 					func(s *terraform.State) error {
 						stdout, _ := os.ReadFile("stdout.log")
 						assert.Equal("hello\n", string(stdout))
@@ -89,7 +109,9 @@ func TestRun_PlanEnv(t *testing.T) {
 					resource.TestCheckResourceAttr("oneshot_run.hello", "stderr_log", "stderr.log"),
 					resource.TestCheckResourceAttr("oneshot_run.hello", "plan_stdout_log", "plan-stdout.log"),
 					resource.TestCheckResourceAttr("oneshot_run.hello", "plan_stderr_log", "plan-stderr.log"),
-					resource.TestMatchResourceAttr("oneshot_run.hello", "run_at", regexp.MustCompile(`^\d{4}-\d{2}-\d{2} \d{2}:\d{2}:\d{2}\.\d+`)),
+					resource.TestMatchResourceAttr("oneshot_run.hello", "started_at", regexp.MustCompile(`^\d{4}-\d{2}-\d{2}T\d{2}:\d{2}:\d{2}(Z|[+-]\d{2}:\d{2})$`)),
+					resource.TestMatchResourceAttr("oneshot_run.hello", "finished_at", regexp.MustCompile(`^\d{4}-\d{2}-\d{2}T\d{2}:\d{2}:\d{2}(Z|[+-]\d{2}:\d{2})$`)),
+					resource.TestMatchResourceAttr("oneshot_run.hello", "duration", regexp.MustCompile(`^[\d.]+(ns|µs|ms|s)$`)),
 					func(s *terraform.State) error {
 						stdout, _ := os.ReadFile("stdout.log")
 						assert.Equal("plan=\n", string(stdout))
@@ -137,7 +159,9 @@ func TestRun_WithoutPlanCommand(t *testing.T) {
 					resource.TestCheckResourceAttr("oneshot_run.hello", "stderr_log", "stderr.log"),
 					resource.TestCheckResourceAttr("oneshot_run.hello", "plan_stdout_log", "plan-stdout.log"),
 					resource.TestCheckResourceAttr("oneshot_run.hello", "plan_stderr_log", "plan-stderr.log"),
-					resource.TestMatchResourceAttr("oneshot_run.hello", "run_at", regexp.MustCompile(`^\d{4}-\d{2}-\d{2} \d{2}:\d{2}:\d{2}\.\d+`)),
+					resource.TestMatchResourceAttr("oneshot_run.hello", "started_at", regexp.MustCompile(`^\d{4}-\d{2}-\d{2}T\d{2}:\d{2}:\d{2}(Z|[+-]\d{2}:\d{2})$`)),
+					resource.TestMatchResourceAttr("oneshot_run.hello", "finished_at", regexp.MustCompile(`^\d{4}-\d{2}-\d{2}T\d{2}:\d{2}:\d{2}(Z|[+-]\d{2}:\d{2})$`)),
+					resource.TestMatchResourceAttr("oneshot_run.hello", "duration", regexp.MustCompile(`^[\d.]+(ns|µs|ms|s)$`)),
 					func(s *terraform.State) error {
 						stdout, _ := os.ReadFile("stdout.log")
 						assert.Equal("hello\n", string(stdout))
@@ -188,7 +212,9 @@ func TestRun_WithShell(t *testing.T) {
 					resource.TestCheckResourceAttr("oneshot_run.hello", "stderr_log", "stderr.log"),
 					resource.TestCheckResourceAttr("oneshot_run.hello", "plan_stdout_log", "plan-stdout.log"),
 					resource.TestCheckResourceAttr("oneshot_run.hello", "plan_stderr_log", "plan-stderr.log"),
-					resource.TestMatchResourceAttr("oneshot_run.hello", "run_at", regexp.MustCompile(`^\d{4}-\d{2}-\d{2} \d{2}:\d{2}:\d{2}\.\d+`)),
+					resource.TestMatchResourceAttr("oneshot_run.hello", "started_at", regexp.MustCompile(`^\d{4}-\d{2}-\d{2}T\d{2}:\d{2}:\d{2}(Z|[+-]\d{2}:\d{2})$`)),
+					resource.TestMatchResourceAttr("oneshot_run.hello", "finished_at", regexp.MustCompile(`^\d{4}-\d{2}-\d{2}T\d{2}:\d{2}:\d{2}(Z|[+-]\d{2}:\d{2})$`)),
+					resource.TestMatchResourceAttr("oneshot_run.hello", "duration", regexp.MustCompile(`^[\d.]+(ns|µs|ms|s)$`)),
 					func(s *terraform.State) error {
 						stdout, _ := os.ReadFile("stdout.log")
 						assert.Equal("/bin/sh\n", string(stdout))
@@ -237,7 +263,9 @@ func TestRun_RunPlanCommandonlyOnce(t *testing.T) {
 					resource.TestCheckResourceAttr("oneshot_run.hello", "stderr_log", "stderr.log"),
 					resource.TestCheckResourceAttr("oneshot_run.hello", "plan_stdout_log", "plan-stdout.log"),
 					resource.TestCheckResourceAttr("oneshot_run.hello", "plan_stderr_log", "plan-stderr.log"),
-					resource.TestMatchResourceAttr("oneshot_run.hello", "run_at", regexp.MustCompile(`^\d{4}-\d{2}-\d{2} \d{2}:\d{2}:\d{2}\.\d+`)),
+					resource.TestMatchResourceAttr("oneshot_run.hello", "started_at", regexp.MustCompile(`^\d{4}-\d{2}-\d{2}T\d{2}:\d{2}:\d{2}(Z|[+-]\d{2}:\d{2})$`)),
+					resource.TestMatchResourceAttr("oneshot_run.hello", "finished_at", regexp.MustCompile(`^\d{4}-\d{2}-\d{2}T\d{2}:\d{2}:\d{2}(Z|[+-]\d{2}:\d{2})$`)),
+					resource.TestMatchResourceAttr("oneshot_run.hello", "duration", regexp.MustCompile(`^[\d.]+(ns|µs|ms|s)$`)),
 					func(s *terraform.State) error {
 						stdout, _ := os.ReadFile("stdout.log")
 						assert.Equal("hello\n", string(stdout))
@@ -286,7 +314,9 @@ func TestRun_RunPlanCommandonlyOnce(t *testing.T) {
 					resource.TestCheckResourceAttr("oneshot_run.hello", "stderr_log", "stderr.log"),
 					resource.TestCheckResourceAttr("oneshot_run.hello", "plan_stdout_log", "plan-stdout.log"),
 					resource.TestCheckResourceAttr("oneshot_run.hello", "plan_stderr_log", "plan-stderr.log"),
-					resource.TestMatchResourceAttr("oneshot_run.hello", "run_at", regexp.MustCompile(`^\d{4}-\d{2}-\d{2} \d{2}:\d{2}:\d{2}\.\d+`)),
+					resource.TestMatchResourceAttr("oneshot_run.hello", "started_at", regexp.MustCompile(`^\d{4}-\d{2}-\d{2}T\d{2}:\d{2}:\d{2}(Z|[+-]\d{2}:\d{2})$`)),
+					resource.TestMatchResourceAttr("oneshot_run.hello", "finished_at", regexp.MustCompile(`^\d{4}-\d{2}-\d{2}T\d{2}:\d{2}:\d{2}(Z|[+-]\d{2}:\d{2})$`)),
+					resource.TestMatchResourceAttr("oneshot_run.hello", "duration", regexp.MustCompile(`^[\d.]+(ns|µs|ms|s)$`)),
 					func(s *terraform.State) error {
 						// No log
 						_, err := os.Stat("stdout.log")
@@ -339,7 +369,9 @@ func TestRun_RenameLog(t *testing.T) {
 					resource.TestCheckResourceAttr("oneshot_run.hello", "stderr_log", "x-stderr.log"),
 					resource.TestCheckResourceAttr("oneshot_run.hello", "plan_stdout_log", "x-plan-stdout.log"),
 					resource.TestCheckResourceAttr("oneshot_run.hello", "plan_stderr_log", "x-plan-stderr.log"),
-					resource.TestMatchResourceAttr("oneshot_run.hello", "run_at", regexp.MustCompile(`^\d{4}-\d{2}-\d{2} \d{2}:\d{2}:\d{2}\.\d+`)),
+					resource.TestMatchResourceAttr("oneshot_run.hello", "started_at", regexp.MustCompile(`^\d{4}-\d{2}-\d{2}T\d{2}:\d{2}:\d{2}(Z|[+-]\d{2}:\d{2})$`)),
+					resource.TestMatchResourceAttr("oneshot_run.hello", "finished_at", regexp.MustCompile(`^\d{4}-\d{2}-\d{2}T\d{2}:\d{2}:\d{2}(Z|[+-]\d{2}:\d{2})$`)),
+					resource.TestMatchResourceAttr("oneshot_run.hello", "duration", regexp.MustCompile(`^[\d.]+(ns|µs|ms|s)$`)),
 					func(s *terraform.State) error {
 						stdout, _ := os.ReadFile("x-stdout.log")
 						assert.Equal("hello\n", string(stdout))
@@ -437,7 +469,9 @@ func TestRun_WithWorkingDir(t *testing.T) {
 					resource.TestCheckResourceAttr("oneshot_run.hello", "stderr_log", "stderr.log"),
 					resource.TestCheckResourceAttr("oneshot_run.hello", "plan_stdout_log", "plan-stdout.log"),
 					resource.TestCheckResourceAttr("oneshot_run.hello", "plan_stderr_log", "plan-stderr.log"),
-					resource.TestMatchResourceAttr("oneshot_run.hello", "run_at", regexp.MustCompile(`^\d{4}-\d{2}-\d{2} \d{2}:\d{2}:\d{2}\.\d+`)),
+					resource.TestMatchResourceAttr("oneshot_run.hello", "started_at", regexp.MustCompile(`^\d{4}-\d{2}-\d{2}T\d{2}:\d{2}:\d{2}(Z|[+-]\d{2}:\d{2})$`)),
+					resource.TestMatchResourceAttr("oneshot_run.hello", "finished_at", regexp.MustCompile(`^\d{4}-\d{2}-\d{2}T\d{2}:\d{2}:\d{2}(Z|[+-]\d{2}:\d{2})$`)),
+					resource.TestMatchResourceAttr("oneshot_run.hello", "duration", regexp.MustCompile(`^[\d.]+(ns|µs|ms|s)$`)),
 					func(s *terraform.State) error {
 						stdout, _ := os.ReadFile("workdir/stdout.log")
 						assert.Equal("hello\n", string(stdout))
@@ -515,7 +549,9 @@ func TestRun_WithTriggers(t *testing.T) {
 					resource.TestCheckResourceAttr("oneshot_run.hello", "stderr_log", "stderr.log"),
 					resource.TestCheckResourceAttr("oneshot_run.hello", "plan_stdout_log", "plan-stdout.log"),
 					resource.TestCheckResourceAttr("oneshot_run.hello", "plan_stderr_log", "plan-stderr.log"),
-					resource.TestMatchResourceAttr("oneshot_run.hello", "run_at", regexp.MustCompile(`^\d{4}-\d{2}-\d{2} \d{2}:\d{2}:\d{2}\.\d+`)),
+					resource.TestMatchResourceAttr("oneshot_run.hello", "started_at", regexp.MustCompile(`^\d{4}-\d{2}-\d{2}T\d{2}:\d{2}:\d{2}(Z|[+-]\d{2}:\d{2})$`)),
+					resource.TestMatchResourceAttr("oneshot_run.hello", "finished_at", regexp.MustCompile(`^\d{4}-\d{2}-\d{2}T\d{2}:\d{2}:\d{2}(Z|[+-]\d{2}:\d{2})$`)),
+					resource.TestMatchResourceAttr("oneshot_run.hello", "duration", regexp.MustCompile(`^[\d.]+(ns|µs|ms|s)$`)),
 					resource.TestCheckResourceAttr("oneshot_run.hello", "triggers.%", "1"),
 					resource.TestCheckResourceAttr("oneshot_run.hello", "triggers.foo", "bar"),
 					func(s *terraform.State) error {
@@ -563,7 +599,9 @@ func TestRun_WithTriggers(t *testing.T) {
 					resource.TestCheckResourceAttr("oneshot_run.hello", "stderr_log", "stderr.log"),
 					resource.TestCheckResourceAttr("oneshot_run.hello", "plan_stdout_log", "plan-stdout.log"),
 					resource.TestCheckResourceAttr("oneshot_run.hello", "plan_stderr_log", "plan-stderr.log"),
-					resource.TestMatchResourceAttr("oneshot_run.hello", "run_at", regexp.MustCompile(`^\d{4}-\d{2}-\d{2} \d{2}:\d{2}:\d{2}\.\d+`)),
+					resource.TestMatchResourceAttr("oneshot_run.hello", "started_at", regexp.MustCompile(`^\d{4}-\d{2}-\d{2}T\d{2}:\d{2}:\d{2}(Z|[+-]\d{2}:\d{2})$`)),
+					resource.TestMatchResourceAttr("oneshot_run.hello", "finished_at", regexp.MustCompile(`^\d{4}-\d{2}-\d{2}T\d{2}:\d{2}:\d{2}(Z|[+-]\d{2}:\d{2})$`)),
+					resource.TestMatchResourceAttr("oneshot_run.hello", "duration", regexp.MustCompile(`^[\d.]+(ns|µs|ms|s)$`)),
 					resource.TestCheckResourceAttr("oneshot_run.hello", "triggers.%", "1"),
 					resource.TestCheckResourceAttr("oneshot_run.hello", "triggers.foo", "zoo"),
 					func(s *terraform.State) error {
@@ -633,7 +671,9 @@ func TestRun_SameLog(t *testing.T) {
 					resource.TestCheckResourceAttr("oneshot_run.hello", "stderr_log", "stderr.log"),
 					resource.TestCheckResourceAttr("oneshot_run.hello", "plan_stdout_log", "stdout.log"),
 					resource.TestCheckResourceAttr("oneshot_run.hello", "plan_stderr_log", "stderr.log"),
-					resource.TestMatchResourceAttr("oneshot_run.hello", "run_at", regexp.MustCompile(`^\d{4}-\d{2}-\d{2} \d{2}:\d{2}:\d{2}\.\d+`)),
+					resource.TestMatchResourceAttr("oneshot_run.hello", "started_at", regexp.MustCompile(`^\d{4}-\d{2}-\d{2}T\d{2}:\d{2}:\d{2}(Z|[+-]\d{2}:\d{2})$`)),
+					resource.TestMatchResourceAttr("oneshot_run.hello", "finished_at", regexp.MustCompile(`^\d{4}-\d{2}-\d{2}T\d{2}:\d{2}:\d{2}(Z|[+-]\d{2}:\d{2})$`)),
+					resource.TestMatchResourceAttr("oneshot_run.hello", "duration", regexp.MustCompile(`^[\d.]+(ns|µs|ms|s)$`)),
 					func(s *terraform.State) error {
 						stdout, _ := os.ReadFile("stdout.log")
 						assert.Equal("hello\n", string(stdout))
@@ -646,3 +686,1804 @@ func TestRun_SameLog(t *testing.T) {
 		},
 	})
 }
+
+func TestRun_EncryptLog(t *testing.T) {
+	assert := assert.New(t)
+
+	cwd, _ := os.Getwd()
+	os.Chdir(t.TempDir())
+	defer os.Chdir(cwd)
+
+	identity, _ := age.GenerateX25519Identity()
+
+	resource.Test(t, resource.TestCase{
+		IsUnitTest:               true,
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: fmt.Sprintf(`
+					provider "oneshot" {
+						log_encryption_recipient = "%s"
+					}
+
+					resource "oneshot_run" "hello" {
+						command         = "echo hello ; echo world 1>&2"
+						plan_command    = "echo plan ; echo planerr 1>&2"
+						plan_stdout_log = "plan-stdout.log"
+						plan_stderr_log = "plan-stderr.log"
+					}
+				`, identity.Recipient()),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr("oneshot_run.hello", "command", "echo hello ; echo world 1>&2"),
+					func(s *terraform.State) error {
+						for name, expected := range map[string]string{
+							"stdout.log":      "hello\n",
+							"stderr.log":      "world\n",
+							"plan-stdout.log": "plan\n",
+							"plan-stderr.log": "planerr\n",
+						} {
+							f, _ := os.Open(name)
+							defer f.Close()
+							var buf bytes.Buffer
+							err := util.Decrypt(&buf, f, identity)
+							assert.NoError(err)
+							assert.Equal(expected, buf.String())
+						}
+						return nil
+					},
+				),
+			},
+		},
+	})
+}
+
+func TestRun_InvalidLogEncryptionRecipient(t *testing.T) {
+	cwd, _ := os.Getwd()
+	os.Chdir(t.TempDir())
+	defer os.Chdir(cwd)
+
+	resource.Test(t, resource.TestCase{
+		IsUnitTest:               true,
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: `
+					provider "oneshot" {
+						log_encryption_recipient = "invalid"
+					}
+
+					resource "oneshot_run" "hello" {
+						command = "echo hello"
+					}
+				`,
+				ExpectError: regexp.MustCompile(`Unable to parse log encryption recipient`),
+			},
+		},
+	})
+}
+
+func TestRun_LogSink(t *testing.T) {
+	assert := assert.New(t)
+
+	cwd, _ := os.Getwd()
+	os.Chdir(t.TempDir())
+	defer os.Chdir(cwd)
+
+	var mu sync.Mutex
+	objects := map[string]string{}
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		defer mu.Unlock()
+		objects[r.URL.Path] = string(body)
+	}))
+	defer ts.Close()
+
+	resource.Test(t, resource.TestCase{
+		IsUnitTest:               true,
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: fmt.Sprintf(`
+					provider "oneshot" {
+						log_sink {
+							endpoint   = "%s"
+							bucket     = "my-bucket"
+							prefix     = "logs/"
+							access_key = "AKID"
+							secret_key = "SECRET"
+						}
+					}
+
+					resource "oneshot_run" "hello" {
+						command = "echo hello ; echo world 1>&2"
+					}
+				`, ts.URL),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestMatchResourceAttr("oneshot_run.hello", "stdout_log_url", regexp.MustCompile(`^`+ts.URL+`/my-bucket/logs/[0-9a-f-]{36}/stdout\.log$`)),
+					resource.TestMatchResourceAttr("oneshot_run.hello", "stderr_log_url", regexp.MustCompile(`^`+ts.URL+`/my-bucket/logs/[0-9a-f-]{36}/stderr\.log$`)),
+					func(s *terraform.State) error {
+						attrs := s.RootModule().Resources["oneshot_run.hello"].Primary.Attributes
+						mu.Lock()
+						defer mu.Unlock()
+						assert.Equal("hello\n", objects[strings.TrimPrefix(attrs["stdout_log_url"], ts.URL)])
+						assert.Equal("world\n", objects[strings.TrimPrefix(attrs["stderr_log_url"], ts.URL)])
+						return nil
+					},
+				),
+			},
+		},
+	})
+}
+
+func TestRun_WithoutLogSink(t *testing.T) {
+	cwd, _ := os.Getwd()
+	os.Chdir(t.TempDir())
+	defer os.Chdir(cwd)
+
+	resource.Test(t, resource.TestCase{
+		IsUnitTest:               true,
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: `
+					resource "oneshot_run" "hello" {
+						command = "echo hello"
+					}
+				`,
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckNoResourceAttr("oneshot_run.hello", "stdout_log_url"),
+					resource.TestCheckNoResourceAttr("oneshot_run.hello", "stderr_log_url"),
+				),
+			},
+		},
+	})
+}
+
+func TestRun_SyslogUnavailable(t *testing.T) {
+	assert := assert.New(t)
+
+	cwd, _ := os.Getwd()
+	os.Chdir(t.TempDir())
+	defer os.Chdir(cwd)
+
+	resource.Test(t, resource.TestCase{
+		IsUnitTest:               true,
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				// The command is executed without syslog
+				Config: `
+					provider "oneshot" {
+						syslog {
+							network = "unixgram"
+							address = "not_exists.sock"
+						}
+					}
+
+					resource "oneshot_run" "hello" {
+						command = "echo hello > hello.txt"
+					}
+				`,
+				Check: func(s *terraform.State) error {
+					assert.FileExists("hello.txt")
+					return nil
+				},
+			},
+		},
+	})
+}
+
+func TestRun_Syslog(t *testing.T) {
+	assert := assert.New(t)
+
+	cwd, _ := os.Getwd()
+	os.Chdir(t.TempDir())
+	defer os.Chdir(cwd)
+
+	pc, _ := net.ListenPacket("udp", "127.0.0.1:0")
+	defer pc.Close()
+
+	var mu sync.Mutex
+	var msgs []string
+
+	go func() {
+		buf := make([]byte, 4096)
+
+		for {
+			n, _, err := pc.ReadFrom(buf)
+
+			if err != nil {
+				return
+			}
+
+			mu.Lock()
+			msgs = append(msgs, string(buf[:n]))
+			mu.Unlock()
+		}
+	}()
+
+	resource.Test(t, resource.TestCase{
+		IsUnitTest:               true,
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: fmt.Sprintf(`
+					provider "oneshot" {
+						syslog {
+							network = "udp"
+							address = "%s"
+							tag     = "oneshot"
+						}
+					}
+
+					resource "oneshot_run" "hello" {
+						command      = "echo hello ; echo world 1>&2"
+						plan_command = "echo plan"
+					}
+				`, pc.LocalAddr()),
+				Check: resource.ComposeTestCheckFunc(
+					func(s *terraform.State) error {
+						time.Sleep(100 * time.Millisecond)
+						mu.Lock()
+						defer mu.Unlock()
+						log := strings.Join(msgs, "\n")
+						assert.Regexp(regexp.MustCompile(`oneshot \d+ start \[oneshot@32473 command_sha256="`+util.CommandHash("echo plan")+`" phase="plan" run_id="[0-9a-f-]{36}"\] started`), log)
+						assert.Regexp(regexp.MustCompile(`oneshot \d+ stdout \[oneshot@32473 phase="plan" run_id="[0-9a-f-]{36}"\] plan`), log)
+						assert.Regexp(regexp.MustCompile(`oneshot \d+ start \[oneshot@32473 command_sha256="`+util.CommandHash("echo hello ; echo world 1>&2")+`" phase="apply" run_id="[0-9a-f-]{36}"\] started`), log)
+						assert.NotContains(log, "echo hello")
+						assert.Regexp(regexp.MustCompile(`oneshot \d+ stdout \[oneshot@32473 phase="apply" run_id="[0-9a-f-]{36}"\] hello`), log)
+						assert.Regexp(regexp.MustCompile(`oneshot \d+ stderr \[oneshot@32473 phase="apply" run_id="[0-9a-f-]{36}"\] world`), log)
+						assert.Regexp(regexp.MustCompile(`oneshot \d+ finish \[oneshot@32473 exit_code="0" phase="apply" run_id="[0-9a-f-]{36}"\] succeeded`), log)
+						return nil
+					},
+				),
+			},
+		},
+	})
+}
+
+func TestRun_AuditLog(t *testing.T) {
+	assert := assert.New(t)
+
+	cwd, _ := os.Getwd()
+	os.Chdir(t.TempDir())
+	defer os.Chdir(cwd)
+
+	resource.Test(t, resource.TestCase{
+		IsUnitTest:               true,
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: `
+					provider "oneshot" {
+						audit_log             = "audit.jsonl"
+						audit_log_command     = true
+						audit_log_environment = ["FOO"]
+					}
+
+					resource "oneshot_run" "hello" {
+						command      = "echo hello ; echo world 1>&2"
+						plan_command = "echo plan"
+						environment  = { FOO = "bar", DATABASE_URL = "postgres://u:pass@db" }
+					}
+				`,
+				Check: resource.ComposeTestCheckFunc(
+					func(s *terraform.State) error {
+						b, _ := os.ReadFile("audit.jsonl")
+						_, err := util.VerifyAuditLog(bytes.NewReader(b))
+						assert.NoError(err)
+
+						lines := strings.Split(strings.TrimSpace(string(b)), "\n")
+						var last util.AuditRecord
+						json.Unmarshal([]byte(lines[len(lines)-1]), &last)
+						assert.Equal("apply", last.Phase)
+						assert.Equal(util.CommandHash("echo hello ; echo world 1>&2"), last.CommandHash)
+						assert.Equal("echo hello ; echo world 1>&2", last.Command)
+						assert.Equal("/bin/bash -c", last.Shell)
+						assert.Equal("default", last.Workspace)
+						assert.Equal(0, last.ExitCode)
+						assert.Equal(6, last.StdoutBytes)
+						assert.Equal(6, last.StderrBytes)
+						assert.Greater(last.MaxRSS, int64(0))
+						assert.Empty(last.Signal)
+						assert.False(last.FinishedAt.Before(last.StartedAt))
+
+						var first util.AuditRecord
+						json.Unmarshal([]byte(lines[0]), &first)
+						assert.Equal("plan", first.Phase)
+						assert.Equal(util.CommandHash("echo plan"), first.CommandHash)
+						assert.Equal("1", first.Environment["ONESHOT_PLAN"])
+						assert.Equal("bar", first.Environment["FOO"])
+						assert.NotContains(first.Environment, "DATABASE_URL")
+						assert.NotContains(first.Environment, "PATH")
+						return nil
+					},
+				),
+			},
+		},
+	})
+}
+
+func TestRun_AuditLogWithoutCommand(t *testing.T) {
+	assert := assert.New(t)
+
+	cwd, _ := os.Getwd()
+	os.Chdir(t.TempDir())
+	defer os.Chdir(cwd)
+
+	resource.Test(t, resource.TestCase{
+		IsUnitTest:               true,
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: `
+					provider "oneshot" {
+						audit_log = "audit.jsonl"
+					}
+
+					resource "oneshot_run" "hello" {
+						command     = "echo hello"
+						environment = { FOO = "bar" }
+					}
+				`,
+				Check: func(s *terraform.State) error {
+					f, _ := os.Open("audit.jsonl")
+					defer f.Close()
+					recs, _ := util.ReadAuditLog(f)
+					assert.Len(recs, 1)
+					assert.Equal(util.CommandHash("echo hello"), recs[0].CommandHash)
+					assert.Empty(recs[0].Command)
+					assert.NotContains(recs[0].Environment, "FOO")
+					assert.Contains(recs[0].Environment, "ONESHOT_RUN_ID")
+					return nil
+				},
+			},
+		},
+	})
+}
+
+func TestRun_ID(t *testing.T) {
+	assert := assert.New(t)
+
+	cwd, _ := os.Getwd()
+	os.Chdir(t.TempDir())
+	defer os.Chdir(cwd)
+
+	resource.Test(t, resource.TestCase{
+		IsUnitTest:               true,
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		TerraformVersionChecks: []tfversion.TerraformVersionCheck{
+			tfversion.SkipBelow(tfversion.Version1_12_0),
+		},
+		Steps: []resource.TestStep{
+			{
+				Config: `
+					resource "oneshot_run" "hello" {
+						command = "echo $ONESHOT_RUN_ID"
+					}
+				`,
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestMatchResourceAttr("oneshot_run.hello", "id", regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}$`)),
+					resource.TestCheckResourceAttrWith("oneshot_run.hello", "id", func(value string) error {
+						stdout, _ := os.ReadFile("stdout.log")
+						assert.Equal(value+"\n", string(stdout))
+						return nil
+					}),
+				),
+				ConfigStateChecks: []statecheck.StateCheck{
+					statecheck.ExpectIdentityValueMatchesState("oneshot_run.hello", tfjsonpath.New("id")),
+				},
+			},
+			{
+				// The ID does not change on update
+				Config: `
+					resource "oneshot_run" "hello" {
+						command       = "echo $ONESHOT_RUN_ID"
+						metrics_label = "hello"
+					}
+				`,
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttrWith("oneshot_run.hello", "id", func(value string) error {
+						stdout, _ := os.ReadFile("stdout.log")
+						assert.Equal(value+"\n", string(stdout))
+						return nil
+					}),
+				),
+			},
+		},
+	})
+}
+
+func TestRun_PlanCommandOnReplace(t *testing.T) {
+	assert := assert.New(t)
+
+	cwd, _ := os.Getwd()
+	os.Chdir(t.TempDir())
+	defer os.Chdir(cwd)
+
+	config := func(version string) string {
+		return fmt.Sprintf(`
+			provider "oneshot" {
+				audit_log = "audit.jsonl"
+			}
+
+			resource "oneshot_run" "hello" {
+				command      = "echo hello"
+				plan_command = "echo plan"
+				triggers     = { version = "%s" }
+			}
+		`, version)
+	}
+
+	planRuns := func() int {
+		f, _ := os.Open("audit.jsonl")
+		defer f.Close()
+		recs, _ := util.ReadAuditLog(f)
+		n := 0
+
+		for _, rec := range recs {
+			if rec.Phase == "plan" {
+				n++
+			}
+		}
+
+		return n
+	}
+
+	var afterCreate, afterReplace int
+
+	resource.Test(t, resource.TestCase{
+		IsUnitTest:               true,
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: config("1"),
+				Check: func(s *terraform.State) error {
+					afterCreate = planRuns()
+					assert.Positive(afterCreate)
+					return nil
+				},
+			},
+			{
+				Config: config("2"),
+				ConfigPlanChecks: resource.ConfigPlanChecks{
+					PreApply: []plancheck.PlanCheck{
+						plancheck.ExpectResourceAction("oneshot_run.hello", plancheck.ResourceActionReplace),
+					},
+				},
+				Check: func(s *terraform.State) error {
+					afterReplace = planRuns()
+					assert.Greater(afterReplace, afterCreate)
+					return nil
+				},
+			},
+			{
+				// The plan command is not executed for no-op plans
+				Config: config("2"),
+				ConfigPlanChecks: resource.ConfigPlanChecks{
+					PreApply: []plancheck.PlanCheck{
+						plancheck.ExpectEmptyPlan(),
+					},
+				},
+				Check: func(s *terraform.State) error {
+					assert.Equal(afterReplace, planRuns())
+					return nil
+				},
+			},
+		},
+	})
+}
+
+func TestRun_CheckCommand(t *testing.T) {
+	assert := assert.New(t)
+
+	cwd, _ := os.Getwd()
+	os.Chdir(t.TempDir())
+	defer os.Chdir(cwd)
+
+	config := `
+		resource "oneshot_run" "hello" {
+			command       = "echo hello >> hello.txt"
+			check_command = "test -f hello.txt"
+		}
+	`
+
+	resource.Test(t, resource.TestCase{
+		IsUnitTest:               true,
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: config,
+			},
+			{
+				// No drift
+				Config: config,
+				ConfigPlanChecks: resource.ConfigPlanChecks{
+					PreApply: []plancheck.PlanCheck{
+						plancheck.ExpectEmptyPlan(),
+					},
+				},
+			},
+			{
+				PreConfig: func() {
+					os.Remove("hello.txt")
+				},
+				Config: config,
+				ConfigPlanChecks: resource.ConfigPlanChecks{
+					PreApply: []plancheck.PlanCheck{
+						plancheck.ExpectResourceAction("oneshot_run.hello", plancheck.ResourceActionCreate),
+					},
+				},
+				Check: resource.ComposeTestCheckFunc(
+					func(s *terraform.State) error {
+						b, _ := os.ReadFile("hello.txt")
+						assert.Equal("hello\n", string(b))
+						return nil
+					},
+				),
+			},
+		},
+	})
+}
+
+func TestRun_CheckExitCode(t *testing.T) {
+	cwd, _ := os.Getwd()
+	os.Chdir(t.TempDir())
+	defer os.Chdir(cwd)
+
+	config := `
+		resource "oneshot_run" "hello" {
+			command         = "echo 2 > check.txt"
+			check_command   = "exit $(cat check.txt)"
+			check_exit_code = 3
+		}
+	`
+
+	resource.Test(t, resource.TestCase{
+		IsUnitTest:               true,
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: config,
+			},
+			{
+				// Exit codes other than check_exit_code are errors
+				Config:      config,
+				ExpectError: regexp.MustCompile("Unable to check command"),
+			},
+			{
+				PreConfig: func() {
+					os.WriteFile("check.txt", []byte("3"), 0644)
+				},
+				Config: config,
+				ConfigPlanChecks: resource.ConfigPlanChecks{
+					PreApply: []plancheck.PlanCheck{
+						plancheck.ExpectResourceAction("oneshot_run.hello", plancheck.ResourceActionCreate),
+					},
+				},
+			},
+		},
+	})
+}
+
+func TestRun_UpdateCommand(t *testing.T) {
+	assert := assert.New(t)
+
+	cwd, _ := os.Getwd()
+	os.Chdir(t.TempDir())
+	defer os.Chdir(cwd)
+
+	config := func(version string, greeting string) string {
+		return fmt.Sprintf(`
+			resource "oneshot_run" "hello" {
+				command         = "echo $GREETING >> hello.txt"
+				update_command  = "echo \"$GREETING $ONESHOT_OLD_UPDATE_TRIGGERS $ONESHOT_NEW_UPDATE_TRIGGERS $ONESHOT_OLD_ENVIRONMENT $ONESHOT_NEW_ENVIRONMENT\" >> update.txt; test \"$GREETING\" != fail"
+				environment     = { GREETING = "%s" }
+				update_triggers = { version = "%s" }
+			}
+		`, greeting, version)
+	}
+
+	resource.Test(t, resource.TestCase{
+		IsUnitTest:               true,
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: config("1", "hello"),
+				Check: resource.ComposeTestCheckFunc(
+					func(s *terraform.State) error {
+						b, _ := os.ReadFile("hello.txt")
+						assert.Equal("hello\n", string(b))
+						assert.NoFileExists("update.txt")
+						return nil
+					},
+				),
+			},
+			{
+				Config: config("2", "hello"),
+				ConfigPlanChecks: resource.ConfigPlanChecks{
+					PreApply: []plancheck.PlanCheck{
+						plancheck.ExpectResourceAction("oneshot_run.hello", plancheck.ResourceActionUpdate),
+					},
+				},
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr("oneshot_run.hello", "update_triggers.version", "2"),
+					func(s *terraform.State) error {
+						b, _ := os.ReadFile("hello.txt")
+						assert.Equal("hello\n", string(b))
+						b, _ = os.ReadFile("update.txt")
+						assert.Equal(`hello {"version":"1"} {"version":"2"} {"GREETING":"hello"} {"GREETING":"hello"}`+"\n", string(b))
+						return nil
+					},
+				),
+			},
+			{
+				Config: config("2", "bonjour"),
+				Check: resource.ComposeTestCheckFunc(
+					func(s *terraform.State) error {
+						b, _ := os.ReadFile("update.txt")
+						assert.Contains(string(b), `bonjour {"version":"2"} {"version":"2"} {"GREETING":"hello"} {"GREETING":"bonjour"}`+"\n")
+						return nil
+					},
+				),
+			},
+			{
+				Config:      config("3", "fail"),
+				ExpectError: regexp.MustCompile("Unable to run update command"),
+			},
+			{
+				RefreshState: true,
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr("oneshot_run.hello", "update_triggers.version", "2"),
+					resource.TestCheckResourceAttr("oneshot_run.hello", "environment.GREETING", "bonjour"),
+				),
+			},
+		},
+	})
+}
+
+func TestRun_Execution(t *testing.T) {
+	cwd, _ := os.Getwd()
+	os.Chdir(t.TempDir())
+	defer os.Chdir(cwd)
+
+	resource.Test(t, resource.TestCase{
+		IsUnitTest:               true,
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: `
+					resource "oneshot_run" "hello" {
+						command = "sleep 1"
+					}
+				`,
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestMatchResourceAttr("oneshot_run.hello", "execution.duration_seconds", regexp.MustCompile(`^1(\.\d+)?$`)),
+					resource.TestCheckResourceAttrSet("oneshot_run.hello", "execution.user_cpu_seconds"),
+					resource.TestCheckResourceAttrSet("oneshot_run.hello", "execution.system_cpu_seconds"),
+					resource.TestMatchResourceAttr("oneshot_run.hello", "execution.max_rss_bytes", regexp.MustCompile(`^[1-9]\d*$`)),
+					resource.TestCheckNoResourceAttr("oneshot_run.hello", "execution.signal"),
+				),
+			},
+		},
+	})
+}
+
+func TestRun_Metrics(t *testing.T) {
+	assert := assert.New(t)
+
+	cwd, _ := os.Getwd()
+	os.Chdir(t.TempDir())
+	defer os.Chdir(cwd)
+
+	resource.Test(t, resource.TestCase{
+		IsUnitTest:               true,
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: `
+					provider "oneshot" {
+						metrics_file = "oneshot.prom"
+					}
+
+					resource "oneshot_run" "hello" {
+						command       = "echo hello"
+						plan_command  = "echo plan"
+						metrics_label = "hello"
+					}
+
+					resource "oneshot_run" "fail" {
+						command = "exit 1"
+					}
+				`,
+				ExpectError: regexp.MustCompile("exit status 1"),
+			},
+			{
+				RefreshState: true,
+				Check: resource.ComposeTestCheckFunc(
+					func(s *terraform.State) error {
+						b, _ := os.ReadFile("oneshot.prom")
+						label := util.CommandHash("exit 1")[:12]
+						assert.Contains(string(b), `oneshot_executions_total{label="hello",phase="apply",outcome="success"} 1`)
+						assert.Contains(string(b), `oneshot_executions_total{label="hello",phase="plan",outcome="success"} 1`)
+						assert.Contains(string(b), `oneshot_executions_total{label="`+label+`",phase="apply",outcome="failure"} 1`)
+						assert.Contains(string(b), `oneshot_execution_duration_seconds_count{label="hello",phase="apply"} 1`)
+						assert.Regexp(`oneshot_last_success_timestamp_seconds\{label="hello",phase="apply"\} \d+`, string(b))
+						assert.NotContains(string(b), `oneshot_last_success_timestamp_seconds{label="`+label+`"`)
+						return nil
+					},
+				),
+			},
+		},
+	})
+}
+
+func TestRun_Notification(t *testing.T) {
+	assert := assert.New(t)
+
+	cwd, _ := os.Getwd()
+	os.Chdir(t.TempDir())
+	defer os.Chdir(cwd)
+
+	var mu sync.Mutex
+	var payloads []map[string]any
+	var headers []http.Header
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		var payload map[string]any
+		json.NewDecoder(r.Body).Decode(&payload)
+		payloads = append(payloads, payload)
+		headers = append(headers, r.Header)
+	}))
+	defer ts.Close()
+
+	resource.Test(t, resource.TestCase{
+		IsUnitTest:               true,
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: fmt.Sprintf(`
+					provider "oneshot" {
+						notification {
+							url     = "%s"
+							headers = { "X-Test" = "oneshot" }
+							secret  = "my-secret"
+						}
+					}
+
+					resource "oneshot_run" "hello" {
+						command       = "echo hello"
+						plan_command  = "echo plan"
+						metrics_label = "hello"
+					}
+				`, ts.URL),
+				Check: resource.ComposeTestCheckFunc(
+					func(s *terraform.State) error {
+						mu.Lock()
+						defer mu.Unlock()
+						assert.Len(payloads, 1)
+						assert.Equal("success", payloads[0]["event"])
+						assert.Equal("apply", payloads[0]["phase"])
+						assert.Equal("hello", payloads[0]["label"])
+						assert.NotContains(payloads[0], "command")
+						assert.Equal(util.CommandHash("echo hello"), payloads[0]["command_sha256"])
+						assert.Equal(float64(0), payloads[0]["exit_code"])
+						assert.Equal("oneshot", headers[0].Get("X-Test"))
+						assert.Regexp(`^sha256=[0-9a-f]{64}$`, headers[0].Get(util.WebhookSignatureHeader))
+						return nil
+					},
+				),
+			},
+		},
+	})
+}
+
+func TestRun_NotificationTemplate(t *testing.T) {
+	assert := assert.New(t)
+
+	cwd, _ := os.Getwd()
+	os.Chdir(t.TempDir())
+	defer os.Chdir(cwd)
+
+	var mu sync.Mutex
+	var bodies []string
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		b, _ := io.ReadAll(r.Body)
+		bodies = append(bodies, string(b))
+	}))
+	defer ts.Close()
+
+	resource.Test(t, resource.TestCase{
+		IsUnitTest:               true,
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: fmt.Sprintf(`
+					provider "oneshot" {
+						notification {
+							url              = "%s"
+							events           = ["failure"]
+							payload_template = "{\"text\": {{ json (printf \"%%s failed with exit code %%d\" .Command .ExitCode) }}}"
+							include_command  = true
+						}
+					}
+
+					resource "oneshot_run" "hello" {
+						command = "echo hello"
+					}
+
+					resource "oneshot_run" "fail" {
+						command = "exit 3"
+					}
+				`, ts.URL),
+				ExpectError: regexp.MustCompile("exit status 3"),
+			},
+			{
+				RefreshState: true,
+				Check: resource.ComposeTestCheckFunc(
+					func(s *terraform.State) error {
+						mu.Lock()
+						defer mu.Unlock()
+						assert.Equal([]string{`{"text": "exit 3 failed with exit code 3"}`}, bodies)
+						return nil
+					},
+				),
+			},
+		},
+	})
+}
+
+func TestRun_Tracing(t *testing.T) {
+	assert := assert.New(t)
+
+	cwd, _ := os.Getwd()
+	os.Chdir(t.TempDir())
+	defer os.Chdir(cwd)
+
+	t.Setenv("TRACEPARENT", "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01")
+
+	resource.Test(t, resource.TestCase{
+		IsUnitTest:               true,
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: `
+					provider "oneshot" {
+						tracing {
+							file = "traces.json"
+						}
+					}
+
+					resource "oneshot_run" "hello" {
+						command         = "echo $TRACEPARENT"
+						plan_command    = "echo $TRACEPARENT"
+						plan_stdout_log = "plan-stdout.log"
+					}
+				`,
+				Check: resource.ComposeTestCheckFunc(
+					func(s *terraform.State) error {
+						stdout, _ := os.ReadFile("stdout.log")
+						assert.Regexp(`^00-0af7651916cd43dd8448eb211c80319c-[0-9a-f]{16}-01\n$`, string(stdout))
+						assert.NotContains(string(stdout), "b7ad6b7169203331")
+						stdout, _ = os.ReadFile("plan-stdout.log")
+						assert.Regexp(`^00-0af7651916cd43dd8448eb211c80319c-[0-9a-f]{16}-01\n$`, string(stdout))
+
+						traces, _ := os.ReadFile("traces.json")
+						assert.Contains(string(traces), `"Name":"oneshot.plan"`)
+						assert.Contains(string(traces), `"Name":"oneshot.apply"`)
+						assert.Contains(string(traces), `"Key":"oneshot.exit_code"`)
+						assert.Contains(string(traces), `"Key":"oneshot.stdout.bytes"`)
+						assert.Contains(string(traces), `"SpanID":"b7ad6b7169203331"`)
+						return nil
+					},
+				),
+			},
+		},
+	})
+}
+
+func TestRun_PostCommandErr(t *testing.T) {
+	assert := assert.New(t)
+
+	cwd, _ := os.Getwd()
+	os.Chdir(t.TempDir())
+	defer os.Chdir(cwd)
+
+	config := `
+		provider "oneshot" {
+			after_each = "exit 2"
+		}
+
+		resource "oneshot_run" "hello" {
+			command      = "echo command >> command.txt"
+			post_command = "exit 1"
+		}
+	`
+
+	resource.Test(t, resource.TestCase{
+		IsUnitTest:               true,
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				// The resource is not tainted, so the command is not executed again
+				Config: config,
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr("oneshot_run.hello", "exit_code", "0"),
+				),
+			},
+			{
+				Config: config,
+				ConfigPlanChecks: resource.ConfigPlanChecks{
+					PreApply: []plancheck.PlanCheck{
+						plancheck.ExpectResourceAction("oneshot_run.hello", plancheck.ResourceActionNoop),
+					},
+				},
+				Check: func(s *terraform.State) error {
+					command, _ := os.ReadFile("command.txt")
+					assert.Equal("command\n", string(command))
+					return nil
+				},
+			},
+		},
+	})
+}
+
+func TestRun_MetricsPlanExitCodeSemanticsDetailed(t *testing.T) {
+	assert := assert.New(t)
+
+	cwd, _ := os.Getwd()
+	os.Chdir(t.TempDir())
+	defer os.Chdir(cwd)
+
+	resource.Test(t, resource.TestCase{
+		IsUnitTest:               true,
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: `
+					provider "oneshot" {
+						metrics_file = "oneshot.prom"
+						audit_log    = "audit.log"
+					}
+
+					resource "oneshot_run" "pending" {
+						command                  = "echo pending"
+						plan_command             = "exit 2"
+						plan_exit_code_semantics = "detailed"
+						metrics_label            = "pending"
+					}
+				`,
+				Check: func(s *terraform.State) error {
+					// Exit code 2 of the plan command means changes pending, which is not a failure
+					b, _ := os.ReadFile("oneshot.prom")
+					assert.Contains(string(b), `oneshot_executions_total{label="pending",phase="plan",outcome="success"}`)
+					assert.NotContains(string(b), `outcome="failure"`)
+
+					f, _ := os.Open("audit.log")
+					defer f.Close()
+					records, _ := util.ReadAuditLog(f)
+
+					for _, rec := range records {
+						assert.True(rec.Succeeded(), rec.Phase)
+					}
+
+					return nil
+				},
+			},
+		},
+	})
+}
+
+func TestRun_PlanOutput(t *testing.T) {
+	cwd, _ := os.Getwd()
+	os.Chdir(t.TempDir())
+	defer os.Chdir(cwd)
+
+	resource.Test(t, resource.TestCase{
+		IsUnitTest:               true,
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: `
+					resource "oneshot_run" "hello" {
+						command            = "echo hello"
+						plan_command       = "echo plan=$ONESHOT_PLAN ; echo plan=$ONESHOT_PLAN 1>&2"
+						warn_plan_output   = true
+						record_plan_output = true
+					}
+				`,
+				ConfigPlanChecks: resource.ConfigPlanChecks{
+					PreApply: []plancheck.PlanCheck{
+						plancheck.ExpectKnownValue("oneshot_run.hello", tfjsonpath.New("plan_output"), knownvalue.StringExact("plan=1\n")),
+					},
+				},
+				ConfigStateChecks: []statecheck.StateCheck{
+					statecheck.ExpectKnownValue("oneshot_run.hello", tfjsonpath.New("plan_output"), knownvalue.StringExact("plan=1\n")),
+				},
+			},
+		},
+	})
+}
+
+func TestRun_PlanOutputNondeterministic(t *testing.T) {
+	cwd, _ := os.Getwd()
+	os.Chdir(t.TempDir())
+	defer os.Chdir(cwd)
+
+	resource.Test(t, resource.TestCase{
+		IsUnitTest:               true,
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				// NOTE: plan_command is executed again at apply time and prints a different output
+				Config: `
+					resource "oneshot_run" "hello" {
+						command          = "echo hello"
+						plan_command     = "date +%s%N ; echo $ONESHOT_RUN_ID"
+						warn_plan_output = true
+					}
+				`,
+				ConfigPlanChecks: resource.ConfigPlanChecks{
+					PreApply: []plancheck.PlanCheck{
+						plancheck.ExpectKnownValue("oneshot_run.hello", tfjsonpath.New("plan_output"), knownvalue.Null()),
+					},
+				},
+				ConfigStateChecks: []statecheck.StateCheck{
+					statecheck.ExpectKnownValue("oneshot_run.hello", tfjsonpath.New("plan_output"), knownvalue.Null()),
+				},
+			},
+		},
+	})
+}
+
+func TestRun_PlanExitCodeSemanticsDetailed(t *testing.T) {
+	assert := assert.New(t)
+
+	cwd, _ := os.Getwd()
+	os.Chdir(t.TempDir())
+	defer os.Chdir(cwd)
+
+	resource.Test(t, resource.TestCase{
+		IsUnitTest:               true,
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: `
+					resource "oneshot_run" "nothing" {
+						command                  = "echo nothing > nothing.txt"
+						plan_command             = "exit 0"
+						plan_exit_code_semantics = "detailed"
+					}
+
+					resource "oneshot_run" "pending" {
+						command                  = "echo pending > pending.txt"
+						plan_command             = "exit 2"
+						plan_exit_code_semantics = "detailed"
+					}
+				`,
+				ConfigStateChecks: []statecheck.StateCheck{
+					statecheck.ExpectKnownValue("oneshot_run.nothing", tfjsonpath.New("skip_reason"), knownvalue.StringExact("plan_command exited with code 0 (nothing to do)")),
+					statecheck.ExpectKnownValue("oneshot_run.nothing", tfjsonpath.New("skipped"), knownvalue.Bool(true)),
+					statecheck.ExpectKnownValue("oneshot_run.nothing", tfjsonpath.New("execution"), knownvalue.Null()),
+					statecheck.ExpectKnownValue("oneshot_run.pending", tfjsonpath.New("skip_reason"), knownvalue.Null()),
+				},
+				Check: func(s *terraform.State) error {
+					assert.NoFileExists("nothing.txt")
+					assert.FileExists("pending.txt")
+					return nil
+				},
+			},
+		},
+	})
+}
+
+func TestRun_PlanExitCodeSemanticsDetailedErr(t *testing.T) {
+	cwd, _ := os.Getwd()
+	os.Chdir(t.TempDir())
+	defer os.Chdir(cwd)
+
+	resource.Test(t, resource.TestCase{
+		IsUnitTest:               true,
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: `
+					resource "oneshot_run" "hello" {
+						command                  = "echo hello"
+						plan_command             = "exit 1"
+						plan_exit_code_semantics = "detailed"
+					}
+				`,
+				ExpectError: regexp.MustCompile(`Unable to plan command, got error: failed to execute command: exit status 1`),
+			},
+		},
+	})
+}
+
+func TestRun_PlanFingerprint(t *testing.T) {
+	assert := assert.New(t)
+
+	cwd, _ := os.Getwd()
+	os.Chdir(t.TempDir())
+	defer os.Chdir(cwd)
+
+	resource.Test(t, resource.TestCase{
+		IsUnitTest:               true,
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: `
+					resource "oneshot_run" "hello" {
+						command      = "echo hello > hello.txt"
+						plan_command = "echo v1 > $ONESHOT_FINGERPRINT_FILE"
+					}
+				`,
+				ConfigStateChecks: []statecheck.StateCheck{
+					statecheck.ExpectKnownValue("oneshot_run.hello", tfjsonpath.New("plan_fingerprint"), knownvalue.StringExact("v1")),
+				},
+				Check: func(s *terraform.State) error {
+					assert.FileExists("hello.txt")
+					return nil
+				},
+			},
+		},
+	})
+}
+
+func TestRun_StalePlanFingerprint(t *testing.T) {
+	assert := assert.New(t)
+
+	cwd, _ := os.Getwd()
+	os.Chdir(t.TempDir())
+	defer os.Chdir(cwd)
+
+	resource.Test(t, resource.TestCase{
+		IsUnitTest:               true,
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				// The fingerprint changes every time the plan command is executed,
+				// so Terraform rejects the saved plan when planning the resource again at apply time
+				Config: `
+					resource "oneshot_run" "hello" {
+						command      = "echo hello > hello.txt"
+						plan_command = "echo x >> count.txt ; wc -l < count.txt > $ONESHOT_FINGERPRINT_FILE"
+					}
+				`,
+				ExpectError: regexp.MustCompile(`(?s)Provider produced inconsistent final plan.*\.plan_fingerprint`),
+			},
+		},
+		CheckDestroy: func(s *terraform.State) error {
+			assert.NoFileExists("hello.txt")
+			return nil
+		},
+	})
+}
+
+func TestRun_UnknownPlanCommand(t *testing.T) {
+	cwd, _ := os.Getwd()
+	os.Chdir(t.TempDir())
+	defer os.Chdir(cwd)
+
+	resource.Test(t, resource.TestCase{
+		IsUnitTest:               true,
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: `
+					resource "oneshot_run" "first" {
+						command = "echo first"
+					}
+
+					resource "oneshot_run" "second" {
+						command            = "echo second"
+						plan_command       = "echo after ${oneshot_run.first.id}"
+						record_plan_output = true
+					}
+				`,
+				ConfigPlanChecks: resource.ConfigPlanChecks{
+					PreApply: []plancheck.PlanCheck{
+						plancheck.ExpectUnknownValue("oneshot_run.second", tfjsonpath.New("plan_output")),
+					},
+				},
+				ConfigStateChecks: []statecheck.StateCheck{
+					// plan_command is executed when planned again with the known values at apply time
+					statecheck.ExpectKnownValue("oneshot_run.second", tfjsonpath.New("plan_output"), knownvalue.StringRegexp(regexp.MustCompile(`^after [0-9a-f-]{36}\n$`))),
+				},
+			},
+		},
+	})
+}
+
+func TestRun_Hooks(t *testing.T) {
+	assert := assert.New(t)
+
+	cwd, _ := os.Getwd()
+	os.Chdir(t.TempDir())
+	defer os.Chdir(cwd)
+
+	resource.Test(t, resource.TestCase{
+		IsUnitTest:               true,
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: `
+					provider "oneshot" {
+						before_each = "echo before_each >> hooks.txt"
+						after_each  = "echo after_each=$ONESHOT_EXIT_CODE >> hooks.txt"
+					}
+
+					resource "oneshot_run" "hello" {
+						command      = "echo command >> hooks.txt"
+						pre_command  = "echo pre=$ONESHOT_HOOK >> hooks.txt"
+						post_command = "echo post=$ONESHOT_HOOK,$ONESHOT_EXIT_CODE >> hooks.txt"
+					}
+				`,
+				Check: func(s *terraform.State) error {
+					hooks, _ := os.ReadFile("hooks.txt")
+					assert.Equal("before_each\npre=pre\ncommand\npost=post,0\nafter_each=0\n", string(hooks))
+					return nil
+				},
+			},
+		},
+	})
+}
+
+func TestRun_PostCommandOnFailure(t *testing.T) {
+	assert := assert.New(t)
+
+	cwd, _ := os.Getwd()
+	os.Chdir(t.TempDir())
+	defer os.Chdir(cwd)
+
+	resource.Test(t, resource.TestCase{
+		IsUnitTest:               true,
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: `
+					resource "oneshot_run" "hello" {
+						command      = "exit 3"
+						post_command = "echo post=$ONESHOT_EXIT_CODE > post.txt"
+					}
+				`,
+				ExpectError: regexp.MustCompile(`Unable to run command, got error: failed to execute command: exit status 3`),
+			},
+		},
+		CheckDestroy: func(s *terraform.State) error {
+			post, _ := os.ReadFile("post.txt")
+			assert.Equal("post=3\n", string(post))
+			return nil
+		},
+	})
+}
+
+func TestRun_PreCommandErr(t *testing.T) {
+	assert := assert.New(t)
+
+	cwd, _ := os.Getwd()
+	os.Chdir(t.TempDir())
+	defer os.Chdir(cwd)
+
+	resource.Test(t, resource.TestCase{
+		IsUnitTest:               true,
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: `
+					resource "oneshot_run" "hello" {
+						command      = "echo command > command.txt"
+						pre_command  = "exit 1"
+						post_command = "echo post > post.txt"
+					}
+				`,
+				ExpectError: regexp.MustCompile(`Unable to run pre command, got error: failed to execute command: exit status 1`),
+			},
+		},
+		CheckDestroy: func(s *terraform.State) error {
+			assert.NoFileExists("command.txt")
+			// post_command matching the started pre_command is executed
+			assert.FileExists("post.txt")
+			return nil
+		},
+	})
+}
+
+func TestRun_PreCommandErrAfterBeforeEach(t *testing.T) {
+	assert := assert.New(t)
+
+	cwd, _ := os.Getwd()
+	os.Chdir(t.TempDir())
+	defer os.Chdir(cwd)
+
+	resource.Test(t, resource.TestCase{
+		IsUnitTest:               true,
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: `
+					provider "oneshot" {
+						before_each = "echo before_each >> hooks.txt"
+						after_each  = "echo after_each >> hooks.txt"
+					}
+
+					resource "oneshot_run" "hello" {
+						command      = "echo command >> hooks.txt"
+						pre_command  = "echo pre >> hooks.txt ; exit 1"
+						post_command = "echo post >> hooks.txt"
+					}
+				`,
+				ExpectError: regexp.MustCompile(`Unable to run pre command, got error: failed to execute command: exit status 1`),
+			},
+		},
+		CheckDestroy: func(s *terraform.State) error {
+			hooks, _ := os.ReadFile("hooks.txt")
+			assert.Equal("before_each\npre\npost\nafter_each\n", string(hooks))
+			return nil
+		},
+	})
+}
+
+func TestRun_BeforeEachErr(t *testing.T) {
+	assert := assert.New(t)
+
+	cwd, _ := os.Getwd()
+	os.Chdir(t.TempDir())
+	defer os.Chdir(cwd)
+
+	resource.Test(t, resource.TestCase{
+		IsUnitTest:               true,
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: `
+					provider "oneshot" {
+						before_each = "echo before_each >> hooks.txt ; exit 1"
+						after_each  = "echo after_each >> hooks.txt"
+					}
+
+					resource "oneshot_run" "hello" {
+						command      = "echo command >> hooks.txt"
+						pre_command  = "echo pre >> hooks.txt"
+						post_command = "echo post >> hooks.txt"
+					}
+				`,
+				ExpectError: regexp.MustCompile(`Unable to run pre command, got error: failed to execute command: exit status 1`),
+			},
+		},
+		CheckDestroy: func(s *terraform.State) error {
+			hooks, _ := os.ReadFile("hooks.txt")
+			assert.Equal("before_each\nafter_each\n", string(hooks))
+			return nil
+		},
+	})
+}
+
+func TestRun_RollbackCommand(t *testing.T) {
+	assert := assert.New(t)
+
+	cwd, _ := os.Getwd()
+	os.Chdir(t.TempDir())
+	defer os.Chdir(cwd)
+
+	resource.Test(t, resource.TestCase{
+		IsUnitTest:               true,
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: `
+					resource "oneshot_run" "hello" {
+						command          = "echo failed ; exit 3"
+						rollback_command = "echo $ONESHOT_EXIT_CODE > rollback.txt ; cat $ONESHOT_FAILED_STDOUT_LOG >> rollback.txt"
+						post_command     = "echo post > post.txt"
+					}
+				`,
+				ExpectError: regexp.MustCompile(`Unable to run command, got error: failed to execute command: exit status 3`),
+			},
+		},
+		CheckDestroy: func(s *terraform.State) error {
+			rollback, _ := os.ReadFile("rollback.txt")
+			assert.Equal("3\nfailed\n", string(rollback))
+			assert.FileExists("post.txt")
+			return nil
+		},
+	})
+}
+
+func TestRun_RollbackCommandErr(t *testing.T) {
+	cwd, _ := os.Getwd()
+	os.Chdir(t.TempDir())
+	defer os.Chdir(cwd)
+
+	resource.Test(t, resource.TestCase{
+		IsUnitTest:               true,
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: `
+					resource "oneshot_run" "hello" {
+						command          = "exit 3"
+						rollback_command = "exit 4"
+					}
+				`,
+				ExpectError: regexp.MustCompile(`Unable to roll back the failed command, got error: failed to execute command: exit status 4`),
+			},
+		},
+	})
+}
+
+func TestRun_RollbackCommandNotExecuted(t *testing.T) {
+	assert := assert.New(t)
+
+	cwd, _ := os.Getwd()
+	os.Chdir(t.TempDir())
+	defer os.Chdir(cwd)
+
+	resource.Test(t, resource.TestCase{
+		IsUnitTest:               true,
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: `
+					resource "oneshot_run" "hello" {
+						command          = "echo hello"
+						rollback_command = "echo rollback > rollback.txt"
+					}
+				`,
+				ConfigStateChecks: []statecheck.StateCheck{
+					statecheck.ExpectKnownValue("oneshot_run.hello", tfjsonpath.New("exit_code"), knownvalue.Int64Exact(0)),
+					statecheck.ExpectKnownValue("oneshot_run.hello", tfjsonpath.New("rollback_exit_code"), knownvalue.Null()),
+				},
+				Check: func(s *terraform.State) error {
+					assert.NoFileExists("rollback.txt")
+					return nil
+				},
+			},
+		},
+	})
+}
+
+func TestRun_Guards(t *testing.T) {
+	assert := assert.New(t)
+
+	cwd, _ := os.Getwd()
+	os.Chdir(t.TempDir())
+	defer os.Chdir(cwd)
+
+	os.WriteFile("exists.txt", []byte{}, 0600)
+
+	resource.Test(t, resource.TestCase{
+		IsUnitTest:               true,
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: `
+					resource "oneshot_run" "creates" {
+						command = "echo creates > creates.txt"
+						creates = "exists.txt"
+					}
+
+					resource "oneshot_run" "removes" {
+						command = "echo removes > removes.txt"
+						removes = "not_exists.txt"
+					}
+
+					resource "oneshot_run" "only_if" {
+						command = "echo only_if > only_if.txt"
+						only_if = "test -f not_exists.txt"
+					}
+
+					resource "oneshot_run" "unless" {
+						command = "echo unless > unless.txt"
+						unless  = "test -f exists.txt"
+					}
+
+					resource "oneshot_run" "run" {
+						command = "echo run > run.txt"
+						creates = "not_exists.txt"
+						removes = "exists.txt"
+						only_if = "test -f exists.txt"
+						unless  = "test -f not_exists.txt"
+					}
+				`,
+				ConfigPlanChecks: resource.ConfigPlanChecks{
+					PreApply: []plancheck.PlanCheck{
+						plancheck.ExpectUnknownValue("oneshot_run.creates", tfjsonpath.New("skipped")),
+					},
+				},
+				ConfigStateChecks: []statecheck.StateCheck{
+					statecheck.ExpectKnownValue("oneshot_run.creates", tfjsonpath.New("skipped"), knownvalue.Bool(true)),
+					statecheck.ExpectKnownValue("oneshot_run.creates", tfjsonpath.New("skip_reason"), knownvalue.StringExact("creates: exists.txt exists")),
+					statecheck.ExpectKnownValue("oneshot_run.removes", tfjsonpath.New("skip_reason"), knownvalue.StringExact("removes: not_exists.txt does not exist")),
+					statecheck.ExpectKnownValue("oneshot_run.only_if", tfjsonpath.New("skip_reason"), knownvalue.StringExact("only_if: exited with code 1")),
+					statecheck.ExpectKnownValue("oneshot_run.unless", tfjsonpath.New("skip_reason"), knownvalue.StringExact("unless: exited with code 0")),
+					statecheck.ExpectKnownValue("oneshot_run.run", tfjsonpath.New("skipped"), knownvalue.Bool(false)),
+					statecheck.ExpectKnownValue("oneshot_run.run", tfjsonpath.New("skip_reason"), knownvalue.Null()),
+				},
+				Check: func(s *terraform.State) error {
+					assert.NoFileExists("creates.txt")
+					assert.NoFileExists("removes.txt")
+					assert.NoFileExists("only_if.txt")
+					assert.NoFileExists("unless.txt")
+					assert.FileExists("run.txt")
+					return nil
+				},
+			},
+		},
+	})
+}
+
+func TestRun_PreviewGuards(t *testing.T) {
+	cwd, _ := os.Getwd()
+	os.Chdir(t.TempDir())
+	defer os.Chdir(cwd)
+
+	os.WriteFile("exists.txt", []byte{}, 0600)
+
+	resource.Test(t, resource.TestCase{
+		IsUnitTest:               true,
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: `
+					resource "oneshot_run" "hello" {
+						command        = "echo hello"
+						creates        = "exists.txt"
+						preview_guards = true
+					}
+				`,
+				ConfigPlanChecks: resource.ConfigPlanChecks{
+					PreApply: []plancheck.PlanCheck{
+						// The guard conditions are evaluated again at apply time
+						plancheck.ExpectUnknownValue("oneshot_run.hello", tfjsonpath.New("skipped")),
+					},
+				},
+				ConfigStateChecks: []statecheck.StateCheck{
+					statecheck.ExpectKnownValue("oneshot_run.hello", tfjsonpath.New("skipped"), knownvalue.Bool(true)),
+				},
+			},
+		},
+	})
+}
+
+func TestRunCheckOutput(t *testing.T) {
+	assert := assert.New(t)
+
+	tests := []struct {
+		expect string
+		fail   string
+		err    string
+	}{
+		{expect: `done`},
+		{expect: `^finished`, err: `stdout does not match expect_stdout_regex: "^finished"`},
+		{fail: `(?i)error`},
+		{fail: `(?i)warn`, err: `stderr line 2 matches fail_on_output_regex: "WARN: deprecated"`},
+		{fail: `[`, err: `invalid fail_on_output_regex`},
+	}
+
+	exec := &provider.Execution{
+		Stdout: "migrating...\ndone\n",
+		Stderr: "INFO: start\nWARN: deprecated\n",
+	}
+
+	for _, tt := range tests {
+		data := provider.RunResourceModel{
+			ExpectStdoutRegex: types.StringNull(),
+			FailOnOutputRegex: types.StringNull(),
+		}
+
+		if tt.expect != "" {
+			data.ExpectStdoutRegex = types.StringValue(tt.expect)
+		}
+
+		if tt.fail != "" {
+			data.FailOnOutputRegex = types.StringValue(tt.fail)
+		}
+
+		err := data.CheckOutput(exec)
+
+		if tt.err == "" {
+			assert.NoError(err)
+		} else {
+			assert.ErrorContains(err, tt.err)
+		}
+	}
+}
+
+func TestRun_VerifyOutput(t *testing.T) {
+	cwd, _ := os.Getwd()
+	os.Chdir(t.TempDir())
+	defer os.Chdir(cwd)
+
+	resource.Test(t, resource.TestCase{
+		IsUnitTest:               true,
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: `
+					resource "oneshot_run" "hello" {
+						command              = "echo migrated ; echo 'ERROR: table not found' 1>&2"
+						expect_stdout_regex  = "migrated"
+						fail_on_output_regex = "^ERROR:"
+					}
+				`,
+				ExpectError: regexp.MustCompile(`stderr line 1 matches fail_on_output_regex: "ERROR: table not found"`),
+			},
+		},
+	})
+}
+
+func TestRun_InvalidRegex(t *testing.T) {
+	assert := assert.New(t)
+
+	cwd, _ := os.Getwd()
+	os.Chdir(t.TempDir())
+	defer os.Chdir(cwd)
+
+	resource.Test(t, resource.TestCase{
+		IsUnitTest:               true,
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: `
+					resource "oneshot_run" "hello" {
+						command              = "echo hello > hello.txt"
+						fail_on_output_regex = "["
+					}
+				`,
+				ExpectError: regexp.MustCompile(`Invalid Regular Expression`),
+			},
+		},
+		CheckDestroy: func(s *terraform.State) error {
+			assert.NoFileExists("hello.txt")
+			return nil
+		},
+	})
+}
+
+func TestRun_VerifyCommand(t *testing.T) {
+	assert := assert.New(t)
+
+	cwd, _ := os.Getwd()
+	os.Chdir(t.TempDir())
+	defer os.Chdir(cwd)
+
+	resource.Test(t, resource.TestCase{
+		IsUnitTest:               true,
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: `
+					resource "oneshot_run" "hello" {
+						command          = "echo hello"
+						verify_command   = "test -f hello.txt"
+						rollback_command = "echo $ONESHOT_EXIT_CODE > rollback.txt"
+					}
+				`,
+				ExpectError: regexp.MustCompile(`verify_command failed, got error: failed to execute command: exit status 1`),
+			},
+		},
+		CheckDestroy: func(s *terraform.State) error {
+			rollback, _ := os.ReadFile("rollback.txt")
+			assert.Equal("1\n", string(rollback))
+			return nil
+		},
+	})
+}
+
+func TestRun_VerificationFailureOutcome(t *testing.T) {
+	assert := assert.New(t)
+
+	cwd, _ := os.Getwd()
+	os.Chdir(t.TempDir())
+	defer os.Chdir(cwd)
+
+	var mu sync.Mutex
+	var payloads []map[string]any
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		var payload map[string]any
+		json.NewDecoder(r.Body).Decode(&payload)
+		payloads = append(payloads, payload)
+	}))
+	defer ts.Close()
+
+	resource.Test(t, resource.TestCase{
+		IsUnitTest:               true,
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: fmt.Sprintf(`
+					provider "oneshot" {
+						metrics_file = "oneshot.prom"
+						audit_log    = "audit.log"
+						after_each   = "echo $ONESHOT_EXIT_CODE > after_each.txt"
+
+						notification {
+							url = "%s"
+						}
+					}
+
+					resource "oneshot_run" "hello" {
+						command              = "echo 'ERROR: failed'"
+						fail_on_output_regex = "^ERROR:"
+						metrics_label        = "hello"
+						post_command         = "echo $ONESHOT_EXIT_CODE > post.txt"
+					}
+				`, ts.URL),
+				ExpectError: regexp.MustCompile(`stdout line 1 matches fail_on_output_regex`),
+			},
+			{
+				RefreshState: true,
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr("oneshot_run.hello", "exit_code", fmt.Sprint(provider.ExitCodeVerificationFailed)),
+					func(s *terraform.State) error {
+						mu.Lock()
+						defer mu.Unlock()
+						assert.Len(payloads, 1)
+						assert.Equal("failure", payloads[0]["event"])
+						assert.Equal(float64(provider.ExitCodeVerificationFailed), payloads[0]["exit_code"])
+						assert.Contains(payloads[0]["error"], "matches fail_on_output_regex")
+
+						b, _ := os.ReadFile("oneshot.prom")
+						assert.Contains(string(b), `oneshot_executions_total{label="hello",phase="apply",outcome="failure"} 1`)
+
+						f, _ := os.Open("audit.log")
+						defer f.Close()
+						records, _ := util.ReadAuditLog(f)
+
+						if assert.NotEmpty(records) {
+							assert.Equal("apply", records[0].Phase)
+							assert.Equal(provider.ExitCodeVerificationFailed, records[0].ExitCode)
+							assert.Contains(records[0].Error, "matches fail_on_output_regex")
+						}
+
+						for _, name := range []string{"post.txt", "after_each.txt"} {
+							b, _ := os.ReadFile(name)
+							assert.Equal("1\n", string(b), name)
+						}
+
+						return nil
+					},
+				),
+			},
+		},
+	})
+}
+
+func TestRun_PlanOutputWithoutPlanCommand(t *testing.T) {
+	cwd, _ := os.Getwd()
+	os.Chdir(t.TempDir())
+	defer os.Chdir(cwd)
+
+	resource.Test(t, resource.TestCase{
+		IsUnitTest:               true,
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: `
+					resource "oneshot_run" "hello" {
+						command = "echo hello"
+					}
+				`,
+				ConfigPlanChecks: resource.ConfigPlanChecks{
+					PreApply: []plancheck.PlanCheck{
+						plancheck.ExpectKnownValue("oneshot_run.hello", tfjsonpath.New("plan_output"), knownvalue.Null()),
+					},
+				},
+				ConfigStateChecks: []statecheck.StateCheck{
+					statecheck.ExpectKnownValue("oneshot_run.hello", tfjsonpath.New("plan_output"), knownvalue.Null()),
+				},
+			},
+		},
+	})
+}
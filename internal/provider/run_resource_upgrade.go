@@ -0,0 +1,74 @@
+package provider
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-go/tfprotov6"
+)
+
+// Format of run_at in the schema version 0. e.g. time.Now().Local().String()
+const runAtLayout = "2006-01-02 15:04:05.999999999 -0700 MST"
+
+func (r *RunResource) UpgradeState(ctx context.Context) map[int64]resource.StateUpgrader {
+	return map[int64]resource.StateUpgrader{
+		// NOTE: The raw state is converted without the prior schema so that all attributes of any version 0 are kept
+		0: {StateUpgrader: upgradeRunResourceStateV0},
+	}
+}
+
+// upgradeRunResourceStateV0 replaces run_at with started_at, finished_at and duration.
+func upgradeRunResourceStateV0(ctx context.Context, req resource.UpgradeStateRequest, resp *resource.UpgradeStateResponse) {
+	if req.RawState == nil {
+		return
+	}
+
+	var state map[string]any
+	dec := json.NewDecoder(bytes.NewReader(req.RawState.JSON))
+	dec.UseNumber()
+	err := dec.Decode(&state)
+
+	if err != nil {
+		resp.Diagnostics.AddError("Unable to Upgrade Resource State", fmt.Sprintf("Unable to parse state, got error: %s", err))
+		return
+	}
+
+	if runAt, ok := state["run_at"].(string); ok {
+		state["started_at"], state["finished_at"], state["duration"] = convertRunAt(runAt, state["execution"])
+	}
+
+	delete(state, "run_at")
+	b, err := json.Marshal(state)
+
+	if err != nil {
+		resp.Diagnostics.AddError("Unable to Upgrade Resource State", err.Error())
+		return
+	}
+
+	resp.DynamicValue = &tfprotov6.DynamicValue{JSON: b}
+}
+
+// convertRunAt converts run_at, the time after the command finished, to started_at, finished_at and duration.
+// started_at and duration are only known if the state has the duration of the execution.
+func convertRunAt(runAt string, execution any) (any, any, any) {
+	finishedAt, err := time.Parse(runAtLayout, runAt)
+
+	if err != nil {
+		return nil, nil, nil
+	}
+
+	if execution, ok := execution.(map[string]any); ok {
+		if secs, ok := execution["duration_seconds"].(json.Number); ok {
+			if f, err := secs.Float64(); err == nil {
+				duration := time.Duration(f * float64(time.Second))
+				return finishedAt.Add(-duration).Format(time.RFC3339), finishedAt.Format(time.RFC3339), duration.String()
+			}
+		}
+	}
+
+	return nil, finishedAt.Format(time.RFC3339), nil
+}
@@ -0,0 +1,80 @@
+package provider_test
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-go/tfprotov6"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/winebarrel/terraform-provider-oneshot/internal/provider"
+)
+
+func upgradeRunState(t *testing.T, rawState string) map[string]any {
+	r := provider.NewRunResource().(resource.ResourceWithUpgradeState)
+	req := resource.UpgradeStateRequest{RawState: &tfprotov6.RawState{JSON: []byte(rawState)}}
+	resp := &resource.UpgradeStateResponse{}
+	r.UpgradeState(context.Background())[0].StateUpgrader(context.Background(), req, resp)
+	require.False(t, resp.Diagnostics.HasError(), resp.Diagnostics)
+
+	// The upgraded state must be compatible with the current schema
+	schemaResp := &resource.SchemaResponse{}
+	r.Schema(context.Background(), resource.SchemaRequest{}, schemaResp)
+	_, err := resp.DynamicValue.Unmarshal(schemaResp.Schema.Type().TerraformType(context.Background()))
+	require.NoError(t, err)
+
+	var state map[string]any
+	json.Unmarshal(resp.DynamicValue.JSON, &state)
+	return state
+}
+
+func TestRunUpgradeStateV0(t *testing.T) {
+	assert := assert.New(t)
+
+	state := upgradeRunState(t, `{
+		"command": "echo hello",
+		"run_at": "2025-01-02 03:04:05.123456789 +0900 JST",
+		"stdout_log": "stdout.log",
+		"triggers": null
+	}`)
+
+	assert.Equal(map[string]any{
+		"command":     "echo hello",
+		"started_at":  nil,
+		"finished_at": "2025-01-02T03:04:05+09:00",
+		"duration":    nil,
+		"stdout_log":  "stdout.log",
+		"triggers":    nil,
+	}, state)
+}
+
+func TestRunUpgradeStateV0_WithExecution(t *testing.T) {
+	assert := assert.New(t)
+
+	state := upgradeRunState(t, `{
+		"command": "echo hello",
+		"run_at": "2025-01-02 03:04:05.5 +0000 UTC",
+		"execution": {"duration_seconds": 65.5, "max_rss_bytes": 3964928}
+	}`)
+
+	assert.Equal("2025-01-02T03:03:00Z", state["started_at"])
+	assert.Equal("2025-01-02T03:04:05Z", state["finished_at"])
+	assert.Equal("1m5.5s", state["duration"])
+	assert.NotContains(state, "run_at")
+	assert.Equal(map[string]any{"duration_seconds": 65.5, "max_rss_bytes": float64(3964928)}, state["execution"])
+}
+
+func TestRunUpgradeStateV0_InvalidRunAt(t *testing.T) {
+	assert := assert.New(t)
+
+	state := upgradeRunState(t, `{"command": "echo hello", "run_at": "yesterday"}`)
+
+	assert.Equal(map[string]any{
+		"command":     "echo hello",
+		"started_at":  nil,
+		"finished_at": nil,
+		"duration":    nil,
+	}, state)
+}
@@ -0,0 +1,45 @@
+package provider_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/schema/validator"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/winebarrel/terraform-provider-oneshot/internal/provider"
+)
+
+func TestRunSchema_Regex(t *testing.T) {
+	assert := assert.New(t)
+	ctx := context.Background()
+	schemaResp := &resource.SchemaResponse{}
+	provider.NewRunResource().Schema(ctx, resource.SchemaRequest{}, schemaResp)
+
+	for _, name := range []string{"expect_stdout_regex", "fail_on_output_regex"} {
+		attr := schemaResp.Schema.Attributes[name].(schema.StringAttribute)
+
+		for value, msg := range map[string]string{
+			`^(ERROR|FATAL):`: "",
+			`(?i)warn`:        "",
+			`[`:               "missing closing ]",
+			`a**`:             "invalid nested repetition operator",
+		} {
+			req := validator.StringRequest{Path: path.Root(name), ConfigValue: types.StringValue(value)}
+			resp := &validator.StringResponse{}
+
+			for _, v := range attr.Validators {
+				v.ValidateString(ctx, req, resp)
+			}
+
+			if msg == "" {
+				assert.False(resp.Diagnostics.HasError(), name+" "+value)
+			} else if assert.True(resp.Diagnostics.HasError(), name+" "+value) {
+				assert.Contains(resp.Diagnostics.Errors()[0].Detail(), msg, name+" "+value)
+			}
+		}
+	}
+}
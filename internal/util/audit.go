@@ -0,0 +1,254 @@
+package util
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+type AuditRecord struct {
+	RunID       string            `json:"run_id"`
+	Phase       string            `json:"phase"`
+	CommandHash string            `json:"command_sha256"`
+	Command     string            `json:"command,omitempty"`
+	Shell       string            `json:"shell"`
+	WorkingDir  string            `json:"working_dir"`
+	User        string            `json:"user"`
+	Host        string            `json:"host"`
+	Workspace   string            `json:"workspace"`
+	StartedAt   time.Time         `json:"started_at"`
+	FinishedAt  time.Time         `json:"finished_at"`
+	ExitCode    int               `json:"exit_code"`
+	Outcome     string            `json:"outcome,omitempty"`
+	StdoutBytes int               `json:"stdout_bytes"`
+	StderrBytes int               `json:"stderr_bytes"`
+	UserCPU     float64           `json:"user_cpu_seconds"`
+	SystemCPU   float64           `json:"system_cpu_seconds"`
+	MaxRSS      int64             `json:"max_rss_bytes"`
+	Signal      string            `json:"signal,omitempty"`
+	StdoutLog   string            `json:"stdout_log,omitempty"`
+	StderrLog   string            `json:"stderr_log,omitempty"`
+	Environment map[string]string `json:"environment,omitempty"`
+	Error       string            `json:"error,omitempty"`
+	PrevHash    string            `json:"prev_hash"`
+	Hash        string            `json:"hash,omitempty"`
+}
+
+func CommandHash(command string) string {
+	return sha256Hex([]byte(command))
+}
+
+// AuditLog appends records to a JSON Lines file.
+// Each record has the hash of the previous record, so that the log is tamper-evident.
+type AuditLog struct {
+	Path string
+	// RecordCommand records the command in plain text. Otherwise, only the hash of the command is recorded.
+	RecordCommand bool
+	// Environment is the names of the environment variables to record in addition to the ones injected by oneshot.
+	Environment []string
+	mu          sync.Mutex
+}
+
+func NewAuditLog(path string) *AuditLog {
+	return &AuditLog{Path: path}
+}
+
+func (a *AuditLog) Append(rec *AuditRecord) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	f, err := os.OpenFile(a.Path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0600)
+
+	if err != nil {
+		return err
+	}
+
+	defer f.Close()
+	err = lockFile(f)
+
+	if err != nil {
+		return err
+	}
+
+	defer unlockFile(f) //nolint:errcheck
+	last, err := readLastLine(f)
+
+	if err != nil {
+		return err
+	}
+
+	rec.PrevHash = ""
+
+	if len(last) > 0 {
+		var prev AuditRecord
+		err = json.Unmarshal(last, &prev)
+
+		if err != nil {
+			return fmt.Errorf("failed to parse the last audit record: %w", err)
+		}
+
+		rec.PrevHash = prev.Hash
+	}
+
+	rec.Hash = ""
+	line, err := json.Marshal(rec)
+
+	if err != nil {
+		return err
+	}
+
+	rec.Hash, err = auditHash(line)
+
+	if err != nil {
+		return err
+	}
+
+	line, err = json.Marshal(rec)
+
+	if err != nil {
+		return err
+	}
+
+	_, err = f.Write(append(line, '\n'))
+
+	return err
+}
+
+// Succeeded returns true if the outcome of the execution is success.
+// The records without the outcome are regarded as succeeded if the exit code is zero.
+func (rec *AuditRecord) Succeeded() bool {
+	if rec.Outcome != "" {
+		return rec.Outcome == OutcomeSuccess
+	}
+
+	return rec.ExitCode == 0
+}
+
+func ReadAuditLog(r io.Reader) ([]AuditRecord, error) {
+	scanner := newAuditScanner(r)
+	var recs []AuditRecord
+
+	for scanner.Scan() {
+		line := scanner.Bytes()
+
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+
+		var rec AuditRecord
+		err := json.Unmarshal(line, &rec)
+
+		if err != nil {
+			return nil, fmt.Errorf("record %d: %w", len(recs)+1, err)
+		}
+
+		recs = append(recs, rec)
+	}
+
+	return recs, scanner.Err()
+}
+
+// VerifyAuditLog checks the hash chain of the audit log and returns the number of verified records.
+func VerifyAuditLog(r io.Reader) (int, error) {
+	scanner := newAuditScanner(r)
+	prevHash := ""
+	n := 0
+
+	for scanner.Scan() {
+		line := scanner.Bytes()
+
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+
+		n++
+		var rec AuditRecord
+		err := json.Unmarshal(line, &rec)
+
+		if err != nil {
+			return n - 1, fmt.Errorf("record %d: %w", n, err)
+		}
+
+		if rec.PrevHash != prevHash {
+			return n - 1, fmt.Errorf("record %d: prev_hash mismatch: expected %q, got %q", n, prevHash, rec.PrevHash)
+		}
+
+		hash, err := auditHash(line)
+
+		if err != nil {
+			return n - 1, fmt.Errorf("record %d: %w", n, err)
+		}
+
+		if rec.Hash != hash {
+			return n - 1, fmt.Errorf("record %d: hash mismatch: expected %q, got %q", n, hash, rec.Hash)
+		}
+
+		prevHash = rec.Hash
+	}
+
+	return n, scanner.Err()
+}
+
+func newAuditScanner(r io.Reader) *bufio.Scanner {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	return scanner
+}
+
+// auditHash calculates the hash of the record without the "hash" field.
+// The fields are sorted by key so that the hash does not depend on the field order.
+func auditHash(line []byte) (string, error) {
+	var fields map[string]json.RawMessage
+	err := json.Unmarshal(line, &fields)
+
+	if err != nil {
+		return "", err
+	}
+
+	delete(fields, "hash")
+	canonical, err := json.Marshal(fields)
+
+	if err != nil {
+		return "", err
+	}
+
+	return sha256Hex(canonical), nil
+}
+
+func readLastLine(f *os.File) ([]byte, error) {
+	const chunkSize = 4096
+	info, err := f.Stat()
+
+	if err != nil {
+		return nil, err
+	}
+
+	var buf []byte
+	offset := info.Size()
+
+	for offset > 0 {
+		size := min(int64(chunkSize), offset)
+		offset -= size
+		chunk := make([]byte, size)
+		_, err := f.ReadAt(chunk, offset)
+
+		if err != nil && !errors.Is(err, io.EOF) {
+			return nil, err
+		}
+
+		buf = append(chunk, buf...)
+		trimmed := bytes.TrimRight(buf, "\n")
+
+		if i := bytes.LastIndexByte(trimmed, '\n'); i >= 0 {
+			return trimmed[i+1:], nil
+		}
+	}
+
+	return bytes.TrimRight(buf, "\n"), nil
+}
@@ -0,0 +1,97 @@
+package util_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/winebarrel/terraform-provider-oneshot/internal/util"
+)
+
+func TestAuditLogAppend_OK(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	cwd, _ := os.Getwd()
+	os.Chdir(t.TempDir())
+	defer os.Chdir(cwd)
+
+	audit := util.NewAuditLog("audit.jsonl")
+	now := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	for _, phase := range []string{"plan", "apply"} {
+		err := audit.Append(&util.AuditRecord{
+			RunID:       "xxx",
+			Phase:       phase,
+			CommandHash: util.CommandHash("echo hello"),
+			StartedAt:   now,
+			FinishedAt:  now.Add(time.Second),
+			StdoutBytes: 6,
+		})
+
+		require.NoError(err)
+	}
+
+	b, _ := os.ReadFile("audit.jsonl")
+	lines := strings.Split(strings.TrimSpace(string(b)), "\n")
+	require.Len(lines, 2)
+
+	var first, second util.AuditRecord
+	json.Unmarshal([]byte(lines[0]), &first)
+	json.Unmarshal([]byte(lines[1]), &second)
+	assert.Equal("plan", first.Phase)
+	assert.Equal("apply", second.Phase)
+	assert.Equal("", first.PrevHash)
+	assert.Equal(first.Hash, second.PrevHash)
+	assert.Len(second.Hash, 64)
+	assert.Equal("5891b5b522d5df086d0ff0b110fbd9d21bb4fc7163af34d08286a2e846f6be03", util.CommandHash("hello\n"))
+
+	n, err := util.VerifyAuditLog(bytes.NewReader(b))
+	require.NoError(err)
+	assert.Equal(2, n)
+}
+
+func TestVerifyAuditLog_Tampered(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	cwd, _ := os.Getwd()
+	os.Chdir(t.TempDir())
+	defer os.Chdir(cwd)
+
+	audit := util.NewAuditLog("audit.jsonl")
+
+	for _, code := range []int{0, 1, 2} {
+		err := audit.Append(&util.AuditRecord{RunID: "xxx", ExitCode: code})
+		require.NoError(err)
+	}
+
+	b, _ := os.ReadFile("audit.jsonl")
+
+	// Modify the record
+	tampered := strings.Replace(string(b), `"exit_code":1`, `"exit_code":0`, 1)
+	n, err := util.VerifyAuditLog(strings.NewReader(tampered))
+	assert.ErrorContains(err, "record 2: hash mismatch")
+	assert.Equal(1, n)
+
+	// Delete the record
+	lines := strings.SplitAfter(string(b), "\n")
+	n, err = util.VerifyAuditLog(strings.NewReader(lines[0] + lines[2]))
+	assert.ErrorContains(err, "record 2: prev_hash mismatch")
+	assert.Equal(1, n)
+}
+
+func TestAuditRecordSucceeded(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.True((&util.AuditRecord{ExitCode: 0}).Succeeded())
+	assert.False((&util.AuditRecord{ExitCode: 1}).Succeeded())
+	// e.g. the plan command exited with 2 (changes pending) under the detailed exit code semantics
+	assert.True((&util.AuditRecord{ExitCode: 2, Outcome: util.OutcomeSuccess}).Succeeded())
+	assert.False((&util.AuditRecord{ExitCode: 0, Outcome: util.OutcomeFailure}).Succeeded())
+}
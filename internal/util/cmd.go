@@ -2,18 +2,27 @@ package util
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"io"
 	"os"
 	"os/exec"
 
+	"filippo.io/age"
 	"github.com/mattn/go-shellwords"
 )
 
 type Cmd struct {
-	Shell  string
-	Stdout string
-	Stderr string
+	Shell      string
+	Stdout     string
+	Stderr     string
+	Recipients []age.Recipient
+	StdoutTee  io.Writer
+	StderrTee  io.Writer
+	// Set after Run
+	StdoutBytes int
+	StderrBytes int
+	Usage       *ResourceUsage
 }
 
 func NewCmd(shell string, stdout string, stderr string) *Cmd {
@@ -26,6 +35,8 @@ func NewCmd(shell string, stdout string, stderr string) *Cmd {
 	return cmd
 }
 
+// Run executes the command and returns its stdout and stderr.
+// The output is also returned when the command fails.
 func (c *Cmd) Run(command string, extraEnvs ...string) (string, string, error) {
 	envs, args, err := shellwords.ParseWithEnvs(c.Shell)
 
@@ -47,38 +58,66 @@ func (c *Cmd) Run(command string, extraEnvs ...string) (string, string, error) {
 	cmd.Env = append(os.Environ(), envs...)
 	var stdout bytes.Buffer
 	var stderr bytes.Buffer
+	stdouts := []io.Writer{&stdout}
+	stderrs := []io.Writer{&stderr}
 
 	if c.Stdout != "" {
-		f, err := os.OpenFile(c.Stdout, os.O_TRUNC|os.O_WRONLY|os.O_CREATE, 0600)
+		f, err := openLog(c.Stdout, c.Recipients)
 
 		if err != nil {
 			return "", "", err
 		}
 
 		defer f.Close()
-		cmd.Stdout = io.MultiWriter(&stdout, f)
-	} else {
-		cmd.Stdout = &stdout
+		stdouts = append(stdouts, f)
 	}
 
 	if c.Stderr != "" {
-		f, err := os.OpenFile(c.Stderr, os.O_TRUNC|os.O_WRONLY|os.O_CREATE, 0600)
+		f, err := openLog(c.Stderr, c.Recipients)
 
 		if err != nil {
 			return "", "", err
 		}
 
 		defer f.Close()
-		cmd.Stderr = io.MultiWriter(&stderr, f)
-	} else {
-		cmd.Stderr = &stderr
+		stderrs = append(stderrs, f)
+	}
+
+	if c.StdoutTee != nil {
+		stdouts = append(stdouts, c.StdoutTee)
 	}
 
+	if c.StderrTee != nil {
+		stderrs = append(stderrs, c.StderrTee)
+	}
+
+	cmd.Stdout = io.MultiWriter(stdouts...)
+	cmd.Stderr = io.MultiWriter(stderrs...)
+
 	err = cmd.Run()
+	c.StdoutBytes = stdout.Len()
+	c.StderrBytes = stderr.Len()
+	c.Usage = NewResourceUsage(cmd.ProcessState)
 
 	if err != nil {
-		return "", "", fmt.Errorf("failed to execute command: %w\n[STDOUT] %s\n[STDERR] %s\n", err, stdout.String(), stderr.String()) //nolint:staticcheck
+		return stdout.String(), stderr.String(), fmt.Errorf("failed to execute command: %w\n[STDOUT] %s\n[STDERR] %s\n", err, stdout.String(), stderr.String()) //nolint:staticcheck
 	}
 
 	return stdout.String(), stderr.String(), nil
 }
+
+// ExitCode returns the exit code of the command from the error returned by Run.
+// It returns -1 if the command could not be executed or was terminated by a signal.
+func ExitCode(err error) int {
+	if err == nil {
+		return 0
+	}
+
+	var exitErr *exec.ExitError
+
+	if errors.As(err, &exitErr) {
+		return exitErr.ExitCode()
+	}
+
+	return -1
+}
@@ -3,6 +3,7 @@ package util_test
 import (
 	"os"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -36,8 +37,10 @@ func TestCmdRun_WithEnv(t *testing.T) {
 func TestCmdRun_Err(t *testing.T) {
 	assert := assert.New(t)
 	cmd := util.NewCmd("/bin/bash -c", "/dev/null", "/dev/null")
-	_, _, err := cmd.Run("echo stdout ; echo stderr 1>&2 ; false")
+	stdout, stderr, err := cmd.Run("echo stdout ; echo stderr 1>&2 ; false")
 	assert.ErrorContains(err, "failed to execute command: exit status 1\n[STDOUT] stdout\n\n[STDERR] stderr\n\n")
+	assert.Equal("stdout\n", stdout)
+	assert.Equal("stderr\n", stderr)
 }
 
 func TestCmdRun_WithLog(t *testing.T) {
@@ -60,3 +63,33 @@ func TestCmdRun_WithLog(t *testing.T) {
 	stderrLog, _ := os.ReadFile("stderr.log")
 	assert.Equal("stderr\n", string(stderrLog))
 }
+
+func TestExitCode(t *testing.T) {
+	assert := assert.New(t)
+	cmd := util.NewCmd("/bin/bash -c", "", "")
+	_, _, err := cmd.Run("exit 3")
+	assert.Equal(3, util.ExitCode(err))
+	_, _, err = cmd.Run("true")
+	assert.Equal(0, util.ExitCode(err))
+	cmd = util.NewCmd("/no/such/shell", "", "")
+	_, _, err = cmd.Run("true")
+	assert.Equal(-1, util.ExitCode(err))
+}
+
+func TestCmdUsage(t *testing.T) {
+	assert := assert.New(t)
+	cmd := util.NewCmd("/bin/bash -c", "", "")
+	_, _, err := cmd.Run("i=0; while [ $i -lt 100000 ]; do i=$((i+1)); done")
+	assert.NoError(err)
+	assert.Greater(cmd.Usage.UserTime+cmd.Usage.SystemTime, time.Duration(0))
+	assert.Greater(cmd.Usage.MaxRSS, int64(0))
+	assert.Equal("", cmd.Usage.Signal)
+
+	_, _, err = cmd.Run("kill -TERM $$")
+	assert.Equal(-1, util.ExitCode(err))
+	assert.Equal("terminated", cmd.Usage.Signal)
+
+	cmd = util.NewCmd("/no/such/shell", "", "")
+	cmd.Run("true")
+	assert.Nil(cmd.Usage)
+}
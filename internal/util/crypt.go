@@ -0,0 +1,78 @@
+package util
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"filippo.io/age"
+)
+
+type encryptedWriter struct {
+	io.WriteCloser
+	file *os.File
+}
+
+func (w *encryptedWriter) Close() error {
+	err := w.WriteCloser.Close()
+
+	if cerr := w.file.Close(); err == nil {
+		err = cerr
+	}
+
+	return err
+}
+
+func ParseRecipients(s string) ([]age.Recipient, error) {
+	recipients, err := age.ParseRecipients(strings.NewReader(s))
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse recipients: %w", err)
+	}
+
+	return recipients, nil
+}
+
+func ParseIdentities(s string) ([]age.Identity, error) {
+	identities, err := age.ParseIdentities(strings.NewReader(s))
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse identities: %w", err)
+	}
+
+	return identities, nil
+}
+
+func Decrypt(dst io.Writer, src io.Reader, identities ...age.Identity) error {
+	r, err := age.Decrypt(src, identities...)
+
+	if err != nil {
+		return fmt.Errorf("failed to decrypt: %w", err)
+	}
+
+	_, err = io.Copy(dst, r)
+
+	return err
+}
+
+func openLog(name string, recipients []age.Recipient) (io.WriteCloser, error) {
+	f, err := os.OpenFile(name, os.O_TRUNC|os.O_WRONLY|os.O_CREATE, 0600)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(recipients) == 0 {
+		return f, nil
+	}
+
+	w, err := age.Encrypt(f, recipients...)
+
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	return &encryptedWriter{WriteCloser: w, file: f}, nil
+}
@@ -0,0 +1,76 @@
+package util_test
+
+import (
+	"bytes"
+	"os"
+	"testing"
+
+	"filippo.io/age"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/winebarrel/terraform-provider-oneshot/internal/util"
+)
+
+func TestCmdRun_WithEncryptedLog(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	cwd, _ := os.Getwd()
+	os.Chdir(t.TempDir())
+	defer os.Chdir(cwd)
+
+	identity, _ := age.GenerateX25519Identity()
+	recipients, err := util.ParseRecipients(identity.Recipient().String())
+	require.NoError(err)
+
+	cmd := util.NewCmd("/bin/bash -c", "stdout.log", "stderr.log")
+	cmd.Recipients = recipients
+	stdout, stderr, err := cmd.Run("echo stdout ; echo stderr 1>&2")
+
+	require.NoError(err)
+	assert.Equal("stdout\n", stdout)
+	assert.Equal("stderr\n", stderr)
+
+	stdoutLog, _ := os.ReadFile("stdout.log")
+	assert.NotContains(string(stdoutLog), "stdout")
+	var buf bytes.Buffer
+	err = util.Decrypt(&buf, bytes.NewReader(stdoutLog), identity)
+	require.NoError(err)
+	assert.Equal("stdout\n", buf.String())
+
+	stderrLog, _ := os.ReadFile("stderr.log")
+	assert.NotContains(string(stderrLog), "stderr")
+	buf.Reset()
+	err = util.Decrypt(&buf, bytes.NewReader(stderrLog), identity)
+	require.NoError(err)
+	assert.Equal("stderr\n", buf.String())
+}
+
+func TestParseRecipients_Err(t *testing.T) {
+	assert := assert.New(t)
+	_, err := util.ParseRecipients("invalid")
+	assert.ErrorContains(err, "failed to parse recipients")
+}
+
+func TestDecrypt_WrongIdentity(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	cwd, _ := os.Getwd()
+	os.Chdir(t.TempDir())
+	defer os.Chdir(cwd)
+
+	identity, _ := age.GenerateX25519Identity()
+	other, _ := age.GenerateX25519Identity()
+
+	cmd := util.NewCmd("/bin/bash -c", "stdout.log", "/dev/null")
+	cmd.Recipients = []age.Recipient{identity.Recipient()}
+	_, _, err := cmd.Run("echo stdout")
+	require.NoError(err)
+
+	f, _ := os.Open("stdout.log")
+	defer f.Close()
+	var buf bytes.Buffer
+	err = util.Decrypt(&buf, f, other)
+	assert.ErrorContains(err, "failed to decrypt")
+}
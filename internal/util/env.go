@@ -0,0 +1,40 @@
+package util
+
+import (
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// injectedEnvPattern matches the variables that oneshot passes to the commands.
+var injectedEnvPattern = regexp.MustCompile(`^(ONESHOT_[A-Z0-9_]+|TRACEPARENT|TRACESTATE)$`)
+
+// valueEnvs are the injected variables that have the values of the environment attribute.
+var valueEnvs = []string{"ONESHOT_OLD_ENVIRONMENT", "ONESHOT_NEW_ENVIRONMENT"}
+
+// AuditEnv converts "KEY=VALUE" pairs to a map of the variables to record in the audit log.
+// Only the variables injected by oneshot and the variables in the allowlist are recorded,
+// because the environment may have secrets whose names do not look like secrets.
+func AuditEnv(injected []string, environ []string, allowlist []string) map[string]string {
+	env := map[string]string{}
+
+	for _, kv := range injected {
+		k, v, ok := strings.Cut(kv, "=")
+
+		if !ok || !injectedEnvPattern.MatchString(k) || slices.Contains(valueEnvs, k) {
+			continue
+		}
+
+		env[k] = v
+	}
+
+	for _, kv := range append(environ, injected...) {
+		k, v, ok := strings.Cut(kv, "=")
+
+		if ok && slices.Contains(allowlist, k) {
+			env[k] = v
+		}
+	}
+
+	return env
+}
@@ -0,0 +1,38 @@
+package util_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/winebarrel/terraform-provider-oneshot/internal/util"
+)
+
+func TestAuditEnv(t *testing.T) {
+	assert := assert.New(t)
+
+	env := util.AuditEnv(
+		[]string{
+			"ONESHOT_PLAN=1",
+			"ONESHOT_RUN_ID=run-1",
+			"ONESHOT_NEW_ENVIRONMENT={\"DB_URL\":\"postgres://u:pass@db\"}",
+			"TRACEPARENT=00-xxx-yyy-01",
+			"invalid",
+		},
+		[]string{
+			"PATH=/usr/bin:/bin",
+			"FOO=a=b",
+			"DATABASE_URL=postgres://u:pass@db",
+			"GITHUB_PAT=xxx",
+			"ONESHOT_LOG_IDENTITY=xxx",
+		},
+		[]string{"PATH", "FOO"},
+	)
+
+	assert.Equal(map[string]string{
+		"ONESHOT_PLAN":   "1",
+		"ONESHOT_RUN_ID": "run-1",
+		"TRACEPARENT":    "00-xxx-yyy-01",
+		"PATH":           "/usr/bin:/bin",
+		"FOO":            "a=b",
+	}, env)
+}
@@ -0,0 +1,16 @@
+//go:build windows
+
+package util
+
+import (
+	"os"
+)
+
+// NOTE: File locking is not supported on Windows
+func lockFile(f *os.File) error {
+	return nil
+}
+
+func unlockFile(f *os.File) error {
+	return nil
+}
@@ -0,0 +1,293 @@
+package util
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	DurationBuckets = []float64{0.1, 0.5, 1, 5, 10, 30, 60, 300, 600, 1800, 3600}
+
+	metricsLineRe  = regexp.MustCompile(`^(\w+)\{(.*)\} (\S+)$`)
+	metricsLabelRe = regexp.MustCompile(`(\w+)="((?:[^"\\]|\\.)*)"`)
+)
+
+const (
+	OutcomeSuccess = "success"
+	OutcomeFailure = "failure"
+)
+
+// Outcome returns the outcome of the execution for the metrics and the audit log.
+func Outcome(succeeded bool) string {
+	if succeeded {
+		return OutcomeSuccess
+	}
+
+	return OutcomeFailure
+}
+
+const (
+	metricExecutions  = "oneshot_executions_total"
+	metricDuration    = "oneshot_execution_duration_seconds"
+	metricLastSuccess = "oneshot_last_success_timestamp_seconds"
+)
+
+type MetricsSample struct {
+	Label      string
+	Phase      string
+	Succeeded  bool
+	Duration   time.Duration
+	FinishedAt time.Time
+}
+
+type metricsKey struct {
+	label string
+	phase string
+}
+
+type histogram struct {
+	buckets []float64
+	sum     float64
+	count   float64
+}
+
+type metrics struct {
+	executions  map[metricsKey]map[string]float64
+	durations   map[metricsKey]*histogram
+	lastSuccess map[metricsKey]float64
+}
+
+// MetricsFile writes metrics in the Prometheus text format for the node exporter textfile collector.
+// The metrics in the existing file are carried over, since the provider process does not live across runs.
+type MetricsFile struct {
+	Path string
+	mu   sync.Mutex
+}
+
+func NewMetricsFile(path string) *MetricsFile {
+	return &MetricsFile{Path: path}
+}
+
+func (m *MetricsFile) Record(sample MetricsSample) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	ms, err := loadMetrics(m.Path)
+
+	if err != nil {
+		return err
+	}
+
+	key := metricsKey{label: sample.Label, phase: sample.Phase}
+	outcome := Outcome(sample.Succeeded)
+
+	if sample.Succeeded {
+		ms.lastSuccess[key] = float64(sample.FinishedAt.Unix())
+	}
+
+	if ms.executions[key] == nil {
+		ms.executions[key] = map[string]float64{}
+	}
+
+	ms.executions[key][outcome]++
+	h := ms.histogram(key)
+	secs := sample.Duration.Seconds()
+
+	for i, le := range DurationBuckets {
+		if secs <= le {
+			h.buckets[i]++
+		}
+	}
+
+	h.sum += secs
+	h.count++
+
+	// Write atomically so that the collector does not read a partial file
+	tmp, err := os.CreateTemp(filepath.Dir(m.Path), filepath.Base(m.Path)+".*.tmp")
+
+	if err != nil {
+		return err
+	}
+
+	defer os.Remove(tmp.Name()) //nolint:errcheck
+	_, err = tmp.Write(ms.render())
+
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+
+	if err != nil {
+		return err
+	}
+
+	err = os.Chmod(tmp.Name(), 0644)
+
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), m.Path)
+}
+
+func (ms *metrics) histogram(key metricsKey) *histogram {
+	h, ok := ms.durations[key]
+
+	if !ok {
+		h = &histogram{buckets: make([]float64, len(DurationBuckets))}
+		ms.durations[key] = h
+	}
+
+	return h
+}
+
+func loadMetrics(path string) (*metrics, error) {
+	ms := &metrics{
+		executions:  map[metricsKey]map[string]float64{},
+		durations:   map[metricsKey]*histogram{},
+		lastSuccess: map[metricsKey]float64{},
+	}
+
+	b, err := os.ReadFile(path)
+
+	if errors.Is(err, os.ErrNotExist) {
+		return ms, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(b))
+
+	for scanner.Scan() {
+		m := metricsLineRe.FindStringSubmatch(scanner.Text())
+
+		if m == nil {
+			continue
+		}
+
+		labels := map[string]string{}
+
+		for _, l := range metricsLabelRe.FindAllStringSubmatch(m[2], -1) {
+			labels[l[1]] = unescapeLabel(l[2])
+		}
+
+		v, err := strconv.ParseFloat(m[3], 64)
+
+		if err != nil {
+			continue
+		}
+
+		key := metricsKey{label: labels["label"], phase: labels["phase"]}
+
+		switch m[1] {
+		case metricExecutions:
+			if ms.executions[key] == nil {
+				ms.executions[key] = map[string]float64{}
+			}
+
+			ms.executions[key][labels["outcome"]] = v
+		case metricDuration + "_bucket":
+			for i, le := range DurationBuckets {
+				if labels["le"] == formatFloat(le) {
+					ms.histogram(key).buckets[i] = v
+				}
+			}
+		case metricDuration + "_sum":
+			ms.histogram(key).sum = v
+		case metricDuration + "_count":
+			ms.histogram(key).count = v
+		case metricLastSuccess:
+			ms.lastSuccess[key] = v
+		}
+	}
+
+	return ms, scanner.Err()
+}
+
+func (ms *metrics) render() []byte {
+	var buf bytes.Buffer
+
+	fmt.Fprintf(&buf, "# HELP %s Total number of command executions.\n", metricExecutions)
+	fmt.Fprintf(&buf, "# TYPE %s counter\n", metricExecutions)
+
+	for _, key := range sortedKeys(ms.executions) {
+		outcomes := make([]string, 0, len(ms.executions[key]))
+
+		for outcome := range ms.executions[key] {
+			outcomes = append(outcomes, outcome)
+		}
+
+		sort.Strings(outcomes)
+
+		for _, outcome := range outcomes {
+			fmt.Fprintf(&buf, "%s{%s,outcome=\"%s\"} %s\n", metricExecutions, key.labels(), escapeLabel(outcome), formatFloat(ms.executions[key][outcome]))
+		}
+	}
+
+	fmt.Fprintf(&buf, "# HELP %s Duration of command executions in seconds.\n", metricDuration)
+	fmt.Fprintf(&buf, "# TYPE %s histogram\n", metricDuration)
+
+	for _, key := range sortedKeys(ms.durations) {
+		h := ms.durations[key]
+
+		for i, le := range DurationBuckets {
+			fmt.Fprintf(&buf, "%s_bucket{%s,le=\"%s\"} %s\n", metricDuration, key.labels(), formatFloat(le), formatFloat(h.buckets[i]))
+		}
+
+		fmt.Fprintf(&buf, "%s_bucket{%s,le=\"+Inf\"} %s\n", metricDuration, key.labels(), formatFloat(h.count))
+		fmt.Fprintf(&buf, "%s_sum{%s} %s\n", metricDuration, key.labels(), formatFloat(h.sum))
+		fmt.Fprintf(&buf, "%s_count{%s} %s\n", metricDuration, key.labels(), formatFloat(h.count))
+	}
+
+	fmt.Fprintf(&buf, "# HELP %s Unix time of the last successful command execution.\n", metricLastSuccess)
+	fmt.Fprintf(&buf, "# TYPE %s gauge\n", metricLastSuccess)
+
+	for _, key := range sortedKeys(ms.lastSuccess) {
+		fmt.Fprintf(&buf, "%s{%s} %s\n", metricLastSuccess, key.labels(), formatFloat(ms.lastSuccess[key]))
+	}
+
+	return buf.Bytes()
+}
+
+func (key metricsKey) labels() string {
+	return fmt.Sprintf(`label="%s",phase="%s"`, escapeLabel(key.label), escapeLabel(key.phase))
+}
+
+func sortedKeys[V any](m map[metricsKey]V) []metricsKey {
+	keys := make([]metricsKey, 0, len(m))
+
+	for k := range m {
+		keys = append(keys, k)
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].label != keys[j].label {
+			return keys[i].label < keys[j].label
+		}
+
+		return keys[i].phase < keys[j].phase
+	})
+
+	return keys
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+func escapeLabel(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(s)
+}
+
+func unescapeLabel(s string) string {
+	return strings.NewReplacer(`\\`, `\`, `\"`, `"`, `\n`, "\n").Replace(s)
+}
@@ -0,0 +1,80 @@
+package util_test
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/winebarrel/terraform-provider-oneshot/internal/util"
+)
+
+func TestMetricsFileRecord_OK(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	cwd, _ := os.Getwd()
+	os.Chdir(t.TempDir())
+	defer os.Chdir(cwd)
+
+	finishedAt := time.Unix(1767225600, 0)
+	metrics := util.NewMetricsFile("oneshot.prom")
+
+	for _, sample := range []util.MetricsSample{
+		{Label: "migrate", Phase: "apply", Succeeded: true, Duration: 2 * time.Second, FinishedAt: finishedAt},
+		{Label: "migrate", Phase: "apply", Succeeded: false, Duration: 90 * time.Second, FinishedAt: finishedAt.Add(time.Hour)},
+		{Label: `say "hi"`, Phase: "plan", Succeeded: true, Duration: 50 * time.Millisecond, FinishedAt: finishedAt},
+	} {
+		err := util.NewMetricsFile("oneshot.prom").Record(sample)
+		require.NoError(err)
+	}
+
+	err := metrics.Record(util.MetricsSample{Label: "migrate", Phase: "apply", Succeeded: true, Duration: time.Second, FinishedAt: finishedAt.Add(2 * time.Hour)})
+	require.NoError(err)
+
+	b, _ := os.ReadFile("oneshot.prom")
+	assert.Equal(`# HELP oneshot_executions_total Total number of command executions.
+# TYPE oneshot_executions_total counter
+oneshot_executions_total{label="migrate",phase="apply",outcome="failure"} 1
+oneshot_executions_total{label="migrate",phase="apply",outcome="success"} 2
+oneshot_executions_total{label="say \"hi\"",phase="plan",outcome="success"} 1
+# HELP oneshot_execution_duration_seconds Duration of command executions in seconds.
+# TYPE oneshot_execution_duration_seconds histogram
+oneshot_execution_duration_seconds_bucket{label="migrate",phase="apply",le="0.1"} 0
+oneshot_execution_duration_seconds_bucket{label="migrate",phase="apply",le="0.5"} 0
+oneshot_execution_duration_seconds_bucket{label="migrate",phase="apply",le="1"} 1
+oneshot_execution_duration_seconds_bucket{label="migrate",phase="apply",le="5"} 2
+oneshot_execution_duration_seconds_bucket{label="migrate",phase="apply",le="10"} 2
+oneshot_execution_duration_seconds_bucket{label="migrate",phase="apply",le="30"} 2
+oneshot_execution_duration_seconds_bucket{label="migrate",phase="apply",le="60"} 2
+oneshot_execution_duration_seconds_bucket{label="migrate",phase="apply",le="300"} 3
+oneshot_execution_duration_seconds_bucket{label="migrate",phase="apply",le="600"} 3
+oneshot_execution_duration_seconds_bucket{label="migrate",phase="apply",le="1800"} 3
+oneshot_execution_duration_seconds_bucket{label="migrate",phase="apply",le="3600"} 3
+oneshot_execution_duration_seconds_bucket{label="migrate",phase="apply",le="+Inf"} 3
+oneshot_execution_duration_seconds_sum{label="migrate",phase="apply"} 93
+oneshot_execution_duration_seconds_count{label="migrate",phase="apply"} 3
+oneshot_execution_duration_seconds_bucket{label="say \"hi\"",phase="plan",le="0.1"} 1
+oneshot_execution_duration_seconds_bucket{label="say \"hi\"",phase="plan",le="0.5"} 1
+oneshot_execution_duration_seconds_bucket{label="say \"hi\"",phase="plan",le="1"} 1
+oneshot_execution_duration_seconds_bucket{label="say \"hi\"",phase="plan",le="5"} 1
+oneshot_execution_duration_seconds_bucket{label="say \"hi\"",phase="plan",le="10"} 1
+oneshot_execution_duration_seconds_bucket{label="say \"hi\"",phase="plan",le="30"} 1
+oneshot_execution_duration_seconds_bucket{label="say \"hi\"",phase="plan",le="60"} 1
+oneshot_execution_duration_seconds_bucket{label="say \"hi\"",phase="plan",le="300"} 1
+oneshot_execution_duration_seconds_bucket{label="say \"hi\"",phase="plan",le="600"} 1
+oneshot_execution_duration_seconds_bucket{label="say \"hi\"",phase="plan",le="1800"} 1
+oneshot_execution_duration_seconds_bucket{label="say \"hi\"",phase="plan",le="3600"} 1
+oneshot_execution_duration_seconds_bucket{label="say \"hi\"",phase="plan",le="+Inf"} 1
+oneshot_execution_duration_seconds_sum{label="say \"hi\"",phase="plan"} 0.05
+oneshot_execution_duration_seconds_count{label="say \"hi\"",phase="plan"} 1
+# HELP oneshot_last_success_timestamp_seconds Unix time of the last successful command execution.
+# TYPE oneshot_last_success_timestamp_seconds gauge
+oneshot_last_success_timestamp_seconds{label="migrate",phase="apply"} 1767232800
+oneshot_last_success_timestamp_seconds{label="say \"hi\"",phase="plan"} 1767225600
+`, string(b))
+
+	info, _ := os.Stat("oneshot.prom")
+	assert.Equal(os.FileMode(0644), info.Mode().Perm())
+}
@@ -0,0 +1,194 @@
+package util
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	s3Service    = "s3"
+	s3Algorithm  = "AWS4-HMAC-SHA256"
+	s3DateFormat = "20060102"
+	s3TimeFormat = "20060102T150405Z"
+)
+
+// S3Uploader puts objects to an S3-compatible endpoint using path-style URLs and Signature Version 4.
+type S3Uploader struct {
+	Endpoint  string
+	Region    string
+	Bucket    string
+	AccessKey string
+	SecretKey string
+	Client    *http.Client
+}
+
+func NewS3Uploader(endpoint string, region string, bucket string, accessKey string, secretKey string) *S3Uploader {
+	uploader := &S3Uploader{
+		Endpoint:  strings.TrimRight(endpoint, "/"),
+		Region:    region,
+		Bucket:    bucket,
+		AccessKey: accessKey,
+		SecretKey: secretKey,
+		Client:    &http.Client{Timeout: 60 * time.Second},
+	}
+
+	return uploader
+}
+
+func (u *S3Uploader) ObjectURL(key string) string {
+	return u.Endpoint + u.objectPath(key)
+}
+
+func (u *S3Uploader) Upload(key string, body []byte) (string, error) {
+	url := u.ObjectURL(key)
+	req, err := http.NewRequest(http.MethodPut, url, bytes.NewReader(body))
+
+	if err != nil {
+		return "", err
+	}
+
+	u.Sign(req, sha256Hex(body), time.Now())
+	res, err := u.Client.Do(req)
+
+	if err != nil {
+		return "", err
+	}
+
+	defer res.Body.Close()
+
+	if res.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(res.Body)
+		return "", fmt.Errorf("failed to upload %s: %s: %s", url, res.Status, strings.TrimSpace(string(msg)))
+	}
+
+	return url, nil
+}
+
+func (u *S3Uploader) objectPath(key string) string {
+	return "/" + s3Escape(u.Bucket) + "/" + s3Escape(key)
+}
+
+// Sign adds the Signature Version 4 headers to the request.
+// All headers of the request are signed, so they must be set before signing.
+func (u *S3Uploader) Sign(req *http.Request, payloadHash string, now time.Time) {
+	now = now.UTC()
+	amzDate := now.Format(s3TimeFormat)
+	date := now.Format(s3DateFormat)
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	host := req.Host
+
+	if host == "" {
+		host = req.URL.Host
+	}
+
+	headers := map[string]string{"host": host}
+
+	for k, v := range req.Header {
+		headers[strings.ToLower(k)] = strings.TrimSpace(strings.Join(v, ","))
+	}
+
+	names := make([]string, 0, len(headers))
+
+	for k := range headers {
+		names = append(names, k)
+	}
+
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+
+	for _, k := range names {
+		canonicalHeaders.WriteString(k + ":" + headers[k] + "\n")
+	}
+
+	signedHeaders := strings.Join(names, ";")
+	// NOTE: The path of the endpoint (e.g. https://host/minio) is a part of the canonical URI
+	canonicalURI := req.URL.EscapedPath()
+
+	if canonicalURI == "" {
+		canonicalURI = "/"
+	}
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		canonicalURI,
+		s3CanonicalQuery(req.URL.Query()),
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := strings.Join([]string{date, u.Region, s3Service, "aws4_request"}, "/")
+	stringToSign := strings.Join([]string{s3Algorithm, amzDate, scope, sha256Hex([]byte(canonicalRequest))}, "\n")
+
+	signingKey := hmacSHA256([]byte("AWS4"+u.SecretKey), date)
+	signingKey = hmacSHA256(signingKey, u.Region)
+	signingKey = hmacSHA256(signingKey, s3Service)
+	signingKey = hmacSHA256(signingKey, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(signingKey, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf(
+		"%s Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s3Algorithm, u.AccessKey, scope, signedHeaders, signature,
+	))
+}
+
+func s3CanonicalQuery(query url.Values) string {
+	params := []string{}
+
+	for k, vs := range query {
+		for _, v := range vs {
+			params = append(params, s3EscapeQuery(k)+"="+s3EscapeQuery(v))
+		}
+	}
+
+	sort.Strings(params)
+
+	return strings.Join(params, "&")
+}
+
+func sha256Hex(b []byte) string {
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+// s3Escape encodes each path segment as required by Signature Version 4, keeping "/" as is.
+func s3Escape(s string) string {
+	return s3URIEncode(s, true)
+}
+
+func s3EscapeQuery(s string) string {
+	return s3URIEncode(s, false)
+}
+
+func s3URIEncode(s string, keepSlash bool) string {
+	var b strings.Builder
+
+	for _, c := range []byte(s) {
+		if 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9' ||
+			c == '-' || c == '_' || c == '.' || c == '~' || c == '/' && keepSlash {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+
+	return b.String()
+}
@@ -0,0 +1,205 @@
+package util
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	SeverityErr     = 3
+	SeverityWarning = 4
+	SeverityNotice  = 5
+	SeverityInfo    = 6
+
+	// Private Enterprise Number reserved for documentation (RFC 5612)
+	syslogSDID = "oneshot@32473"
+)
+
+var syslogFacilities = map[string]int{
+	"kern":     0,
+	"user":     1,
+	"mail":     2,
+	"daemon":   3,
+	"auth":     4,
+	"syslog":   5,
+	"lpr":      6,
+	"news":     7,
+	"uucp":     8,
+	"cron":     9,
+	"authpriv": 10,
+	"ftp":      11,
+	"local0":   16,
+	"local1":   17,
+	"local2":   18,
+	"local3":   19,
+	"local4":   20,
+	"local5":   21,
+	"local6":   22,
+	"local7":   23,
+}
+
+// Syslog sends RFC 5424 messages over UDP, TCP or a unix socket.
+type Syslog struct {
+	Network        string
+	Address        string
+	AppName        string
+	Facility       int
+	Hostname       string
+	IncludeCommand bool
+}
+
+func NewSyslog(network string, address string, appName string, facility string) (*Syslog, error) {
+	switch network {
+	case "udp", "tcp", "unix", "unixgram":
+	default:
+		return nil, fmt.Errorf("unsupported syslog network: %s", network)
+	}
+
+	f, ok := syslogFacilities[facility]
+
+	if !ok {
+		return nil, fmt.Errorf("unknown syslog facility: %s", facility)
+	}
+
+	hostname, _ := os.Hostname()
+
+	s := &Syslog{
+		Network:  network,
+		Address:  address,
+		AppName:  appName,
+		Facility: f,
+		Hostname: hostname,
+	}
+
+	return s, nil
+}
+
+func (s *Syslog) Dial() (*SyslogConn, error) {
+	conn, err := net.Dial(s.Network, s.Address)
+
+	if err != nil {
+		return nil, err
+	}
+
+	c := &SyslogConn{
+		syslog: s,
+		conn:   conn,
+		stream: s.Network == "tcp" || s.Network == "unix",
+	}
+
+	return c, nil
+}
+
+type SyslogConn struct {
+	syslog *Syslog
+	conn   net.Conn
+	stream bool
+	mu     sync.Mutex
+}
+
+func (c *SyslogConn) Send(severity int, msgID string, params map[string]string, msg string) error {
+	line := c.format(severity, msgID, params, msg)
+
+	if c.stream {
+		// RFC 6587 octet counting
+		line = fmt.Sprintf("%d %s", len(line), line)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	_, err := c.conn.Write([]byte(line))
+
+	return err
+}
+
+func (c *SyslogConn) format(severity int, msgID string, params map[string]string, msg string) string {
+	var sd strings.Builder
+
+	if len(params) == 0 {
+		sd.WriteString("-")
+	} else {
+		keys := make([]string, 0, len(params))
+
+		for k := range params {
+			keys = append(keys, k)
+		}
+
+		sort.Strings(keys)
+		sd.WriteString("[" + syslogSDID)
+
+		for _, k := range keys {
+			v := strings.NewReplacer(`\`, `\\`, `"`, `\"`, `]`, `\]`).Replace(params[k])
+			fmt.Fprintf(&sd, ` %s="%s"`, k, v)
+		}
+
+		sd.WriteString("]")
+	}
+
+	return fmt.Sprintf("<%d>1 %s %s %s %d %s %s %s",
+		c.syslog.Facility*8+severity,
+		time.Now().Format(time.RFC3339Nano),
+		syslogField(c.syslog.Hostname),
+		syslogField(c.syslog.AppName),
+		os.Getpid(),
+		syslogField(msgID),
+		sd.String(),
+		msg,
+	)
+}
+
+// LineWriter returns a writer that sends each line written to it as a message.
+// Send errors are ignored so that they do not interrupt the command.
+func (c *SyslogConn) LineWriter(severity int, msgID string, params map[string]string) *SyslogLineWriter {
+	return &SyslogLineWriter{conn: c, severity: severity, msgID: msgID, params: params}
+}
+
+func (c *SyslogConn) Close() error {
+	return c.conn.Close()
+}
+
+type SyslogLineWriter struct {
+	conn     *SyslogConn
+	severity int
+	msgID    string
+	params   map[string]string
+	buf      bytes.Buffer
+}
+
+func (w *SyslogLineWriter) Write(p []byte) (int, error) {
+	w.buf.Write(p)
+
+	for {
+		i := bytes.IndexByte(w.buf.Bytes(), '\n')
+
+		if i < 0 {
+			break
+		}
+
+		line := string(w.buf.Next(i + 1))
+		w.conn.Send(w.severity, w.msgID, w.params, strings.TrimRight(line, "\r\n")) //nolint:errcheck
+	}
+
+	return len(p), nil
+}
+
+// Flush sends the remaining partial line, if any.
+func (w *SyslogLineWriter) Flush() {
+	if w.buf.Len() > 0 {
+		w.conn.Send(w.severity, w.msgID, w.params, w.buf.String()) //nolint:errcheck
+		w.buf.Reset()
+	}
+}
+
+func syslogField(s string) string {
+	if s == "" {
+		return "-"
+	}
+
+	return s
+}
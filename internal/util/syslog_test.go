@@ -0,0 +1,97 @@
+package util_test
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/winebarrel/terraform-provider-oneshot/internal/util"
+)
+
+func TestSyslogSend_UDP(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(err)
+	defer pc.Close()
+
+	syslog, err := util.NewSyslog("udp", pc.LocalAddr().String(), "oneshot", "local0")
+	require.NoError(err)
+	conn, err := syslog.Dial()
+	require.NoError(err)
+	defer conn.Close()
+
+	err = conn.Send(util.SeverityInfo, "stdout", map[string]string{"run_id": "xxx", "phase": "apply"}, `hello "world"`)
+	require.NoError(err)
+
+	buf := make([]byte, 1024)
+	n, _, err := pc.ReadFrom(buf)
+	require.NoError(err)
+	assert.Regexp(
+		regexp.MustCompile(`^<134>1 \S+ \S+ oneshot \d+ stdout \[oneshot@32473 phase="apply" run_id="xxx"\] hello "world"$`),
+		string(buf[:n]),
+	)
+}
+
+func TestSyslogSend_TCP(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(err)
+	defer ln.Close()
+
+	syslog, err := util.NewSyslog("tcp", ln.Addr().String(), "oneshot", "user")
+	require.NoError(err)
+	conn, err := syslog.Dial()
+	require.NoError(err)
+
+	w := conn.LineWriter(util.SeverityWarning, "stderr", nil)
+	w.Write([]byte("foo\nba"))
+	w.Write([]byte("r\nzoo"))
+	w.Flush()
+	conn.Close()
+
+	server, err := ln.Accept()
+	require.NoError(err)
+	defer server.Close()
+
+	r := bufio.NewReader(server)
+	var msgs []string
+
+	for {
+		length, err := r.ReadString(' ')
+
+		if err != nil {
+			break
+		}
+
+		n, err := strconv.Atoi(strings.TrimSpace(length))
+		require.NoError(err)
+		msg := make([]byte, n)
+		_, err = io.ReadFull(r, msg)
+		require.NoError(err)
+		msgs = append(msgs, string(msg))
+	}
+
+	require.Len(msgs, 3)
+
+	for i, expected := range []string{"foo", "bar", "zoo"} {
+		assert.Regexp(regexp.MustCompile(`^<12>1 \S+ \S+ oneshot \d+ stderr - `+expected+`$`), msgs[i])
+	}
+}
+
+func TestNewSyslog_Err(t *testing.T) {
+	assert := assert.New(t)
+	_, err := util.NewSyslog("udp", "127.0.0.1:514", "oneshot", "unknown")
+	assert.ErrorContains(err, "unknown syslog facility: unknown")
+	_, err = util.NewSyslog("http", "127.0.0.1:514", "oneshot", "user")
+	assert.ErrorContains(err, "unsupported syslog network: http")
+}
@@ -0,0 +1,87 @@
+package util
+
+import (
+	"context"
+	"os"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+var propagator = propagation.TraceContext{}
+
+// TracingExportTimeout bounds the time to export spans, which blocks the command execution.
+const TracingExportTimeout = 5 * time.Second
+
+// NewTracerProvider creates a TracerProvider that exports spans to the file as JSON, or to the OTLP/HTTP endpoint.
+// If both are empty, the endpoint is configured with the OTEL_EXPORTER_OTLP_* environment variables.
+// Spans are exported synchronously because the provider has no chance to flush them on exit,
+// so the export is not retried and times out in TracingExportTimeout not to block the executions with an unreachable collector.
+func NewTracerProvider(ctx context.Context, serviceName string, endpoint string, file string) (*sdktrace.TracerProvider, error) {
+	var exporter sdktrace.SpanExporter
+	var err error
+
+	if file != "" {
+		var f *os.File
+		f, err = os.OpenFile(file, os.O_APPEND|os.O_WRONLY|os.O_CREATE, 0600)
+
+		if err != nil {
+			return nil, err
+		}
+
+		exporter, err = stdouttrace.New(stdouttrace.WithWriter(f))
+	} else {
+		opts := []otlptracehttp.Option{
+			otlptracehttp.WithTimeout(TracingExportTimeout),
+			otlptracehttp.WithRetry(otlptracehttp.RetryConfig{Enabled: false}),
+		}
+
+		if endpoint != "" {
+			opts = append(opts, otlptracehttp.WithEndpointURL(endpoint))
+		}
+
+		exporter, err = otlptracehttp.New(ctx, opts...)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithSyncer(exporter),
+		sdktrace.WithResource(resource.NewSchemaless(attribute.String("service.name", serviceName))),
+	)
+
+	return tp, nil
+}
+
+// ExtractTraceContext returns the context with the remote span context in TRACEPARENT/TRACESTATE.
+func ExtractTraceContext(ctx context.Context) context.Context {
+	carrier := propagation.MapCarrier{
+		"traceparent": os.Getenv("TRACEPARENT"),
+		"tracestate":  os.Getenv("TRACESTATE"),
+	}
+
+	return propagator.Extract(ctx, carrier)
+}
+
+// TraceEnv returns TRACEPARENT/TRACESTATE of the span in the context to pass to the child process.
+func TraceEnv(ctx context.Context) []string {
+	carrier := propagation.MapCarrier{}
+	propagator.Inject(ctx, carrier)
+	var envs []string
+
+	for _, k := range []string{"traceparent", "tracestate"} {
+		if v := carrier.Get(k); v != "" {
+			envs = append(envs, strings.ToUpper(k)+"="+v)
+		}
+	}
+
+	return envs
+}
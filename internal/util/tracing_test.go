@@ -0,0 +1,96 @@
+package util_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/winebarrel/terraform-provider-oneshot/internal/util"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+func TestTracerProvider_File(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	cwd, _ := os.Getwd()
+	os.Chdir(t.TempDir())
+	defer os.Chdir(cwd)
+
+	t.Setenv("TRACEPARENT", "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01")
+	t.Setenv("TRACESTATE", "")
+
+	tp, err := util.NewTracerProvider(context.Background(), "oneshot-test", "", "traces.json")
+	require.NoError(err)
+
+	ctx, span := tp.Tracer("test").Start(util.ExtractTraceContext(context.Background()), "oneshot.apply")
+	span.SetAttributes(attribute.Int("oneshot.exit_code", 0))
+	envs := util.TraceEnv(ctx)
+	span.End()
+
+	require.Len(envs, 1)
+	assert.Regexp(`^TRACEPARENT=00-0af7651916cd43dd8448eb211c80319c-[0-9a-f]{16}-01$`, envs[0])
+	assert.NotContains(envs[0], "b7ad6b7169203331")
+
+	b, _ := os.ReadFile("traces.json")
+	assert.Contains(string(b), `"Name":"oneshot.apply"`)
+	assert.Contains(string(b), `"TraceID":"0af7651916cd43dd8448eb211c80319c"`)
+	assert.Contains(string(b), `"SpanID":"b7ad6b7169203331"`)
+	assert.Contains(string(b), `"Key":"oneshot.exit_code"`)
+	assert.Contains(string(b), `"Value":"oneshot-test"`)
+}
+
+func TestTraceEnv_WithoutSpan(t *testing.T) {
+	assert := assert.New(t)
+	assert.Empty(util.TraceEnv(context.Background()))
+}
+
+func TestTracerProvider_OTLP(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	var paths []string
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.Path)
+		w.Header().Set("Content-Type", "application/x-protobuf")
+	}))
+	defer ts.Close()
+
+	tp, err := util.NewTracerProvider(context.Background(), "oneshot-test", ts.URL+"/v1/traces", "")
+	require.NoError(err)
+
+	_, span := tp.Tracer("test").Start(context.Background(), "oneshot.plan")
+	span.End()
+
+	assert.Equal([]string{"/v1/traces"}, paths)
+}
+
+func TestTracerProvider_OTLPUnavailable(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	called := 0
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called++
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer ts.Close()
+
+	tp, err := util.NewTracerProvider(context.Background(), "oneshot-test", ts.URL+"/v1/traces", "")
+	require.NoError(err)
+
+	// The export is not retried
+	start := time.Now()
+	_, span := tp.Tracer("test").Start(context.Background(), "oneshot.plan")
+	span.End()
+
+	assert.Equal(1, called)
+	assert.Less(time.Since(start), util.TracingExportTimeout)
+}
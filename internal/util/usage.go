@@ -0,0 +1,37 @@
+package util
+
+import (
+	"os"
+	"syscall"
+	"time"
+)
+
+// ResourceUsage is the resource usage of the executed process.
+type ResourceUsage struct {
+	UserTime   time.Duration
+	SystemTime time.Duration
+	// MaxRSS is the maximum resident set size in bytes. It is 0 if not supported on the platform.
+	MaxRSS int64
+	// Signal is the name of the signal that terminated the process, or empty if it exited normally.
+	Signal string
+}
+
+// NewResourceUsage returns the resource usage of the exited process.
+// It returns nil if the process was not started.
+func NewResourceUsage(state *os.ProcessState) *ResourceUsage {
+	if state == nil {
+		return nil
+	}
+
+	usage := &ResourceUsage{
+		UserTime:   state.UserTime(),
+		SystemTime: state.SystemTime(),
+		MaxRSS:     maxRSS(state),
+	}
+
+	if ws, ok := state.Sys().(syscall.WaitStatus); ok && ws.Signaled() {
+		usage.Signal = ws.Signal().String()
+	}
+
+	return usage
+}
@@ -0,0 +1,17 @@
+//go:build darwin
+
+package util
+
+import (
+	"os"
+	"syscall"
+)
+
+func maxRSS(state *os.ProcessState) int64 {
+	if rusage, ok := state.SysUsage().(*syscall.Rusage); ok {
+		// ru_maxrss is in bytes on macOS
+		return rusage.Maxrss
+	}
+
+	return 0
+}
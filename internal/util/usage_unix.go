@@ -0,0 +1,17 @@
+//go:build !windows && !darwin
+
+package util
+
+import (
+	"os"
+	"syscall"
+)
+
+func maxRSS(state *os.ProcessState) int64 {
+	if rusage, ok := state.SysUsage().(*syscall.Rusage); ok {
+		// ru_maxrss is in kilobytes
+		return int64(rusage.Maxrss) * 1024
+	}
+
+	return 0
+}
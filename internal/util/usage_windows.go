@@ -0,0 +1,12 @@
+//go:build windows
+
+package util
+
+import (
+	"os"
+)
+
+// NOTE: Max RSS is not supported on Windows
+func maxRSS(state *os.ProcessState) int64 {
+	return 0
+}
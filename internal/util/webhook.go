@@ -0,0 +1,180 @@
+package util
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+	"text/template"
+	"time"
+)
+
+const (
+	EventSuccess = "success"
+	EventFailure = "failure"
+
+	WebhookSignatureHeader = "X-Oneshot-Signature-256"
+)
+
+// WebhookPayload is the default payload of the webhook and the data of the payload template.
+type WebhookPayload struct {
+	Event       string    `json:"event"`
+	RunID       string    `json:"run_id"`
+	Label       string    `json:"label"`
+	Phase       string    `json:"phase"`
+	Command     string    `json:"command,omitempty"`
+	CommandHash string    `json:"command_sha256"`
+	ExitCode    int       `json:"exit_code"`
+	StartedAt   time.Time `json:"started_at"`
+	FinishedAt  time.Time `json:"finished_at"`
+	Duration    float64   `json:"duration_seconds"`
+	User        string    `json:"user"`
+	Host        string    `json:"host"`
+	Workspace   string    `json:"workspace"`
+	Error       string    `json:"error,omitempty"`
+}
+
+// Webhook POSTs a JSON payload of the execution result.
+type Webhook struct {
+	URL            string
+	Headers        map[string]string
+	Events         []string
+	Template       *template.Template
+	Secret         string
+	Retries        int
+	RetryInterval  time.Duration
+	Client         *http.Client
+	IncludeCommand bool
+}
+
+func NewWebhook(url string, headers map[string]string, events []string, payloadTemplate string, secret string, retries int, timeout time.Duration) (*Webhook, error) {
+	for _, event := range events {
+		if event != EventSuccess && event != EventFailure {
+			return nil, fmt.Errorf("unknown webhook event: %s", event)
+		}
+	}
+
+	webhook := &Webhook{
+		URL:           url,
+		Headers:       headers,
+		Events:        events,
+		Secret:        secret,
+		Retries:       retries,
+		RetryInterval: time.Second,
+		Client:        &http.Client{Timeout: timeout},
+	}
+
+	if payloadTemplate != "" {
+		tmpl, err := template.New("payload").Funcs(template.FuncMap{"json": toJSON}).Parse(payloadTemplate)
+
+		if err != nil {
+			return nil, err
+		}
+
+		webhook.Template = tmpl
+	}
+
+	return webhook, nil
+}
+
+// Wants returns true if the event is not filtered out.
+func (w *Webhook) Wants(event string) bool {
+	return len(w.Events) == 0 || slices.Contains(w.Events, event)
+}
+
+// Notify sends the payload, retrying on network errors and 5xx/429 responses.
+// The body is signed with HMAC-SHA256 if the secret is set.
+func (w *Webhook) Notify(ctx context.Context, payload *WebhookPayload) error {
+	if !w.Wants(payload.Event) {
+		return nil
+	}
+
+	body, err := w.render(payload)
+
+	if err != nil {
+		return err
+	}
+
+	for i := 0; ; i++ {
+		var retryable bool
+		retryable, err = w.post(ctx, body)
+
+		if err == nil || !retryable || i >= w.Retries {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return errors.Join(err, ctx.Err())
+		case <-time.After(w.RetryInterval * time.Duration(1<<i)):
+		}
+	}
+}
+
+func (w *Webhook) render(payload *WebhookPayload) ([]byte, error) {
+	if w.Template == nil {
+		return json.Marshal(payload)
+	}
+
+	var buf bytes.Buffer
+	err := w.Template.Execute(&buf, payload)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if !json.Valid(buf.Bytes()) {
+		return nil, fmt.Errorf("payload template does not render valid JSON: %s", buf.String())
+	}
+
+	return buf.Bytes(), nil
+}
+
+func (w *Webhook) post(ctx context.Context, body []byte) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.URL, bytes.NewReader(body))
+
+	if err != nil {
+		return false, err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+
+	for k, v := range w.Headers {
+		req.Header.Set(k, v)
+	}
+
+	if w.Secret != "" {
+		mac := hmac.New(sha256.New, []byte(w.Secret))
+		mac.Write(body)
+		req.Header.Set(WebhookSignatureHeader, "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	}
+
+	res, err := w.Client.Do(req)
+
+	if err != nil {
+		return true, err
+	}
+
+	defer res.Body.Close()
+
+	if res.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(res.Body)
+		retryable := res.StatusCode >= 500 || res.StatusCode == http.StatusTooManyRequests
+		return retryable, fmt.Errorf("failed to notify %s: %s: %s", w.URL, res.Status, strings.TrimSpace(string(msg)))
+	}
+
+	return false, nil
+}
+
+func toJSON(v any) (string, error) {
+	b, err := json.Marshal(v)
+	return string(b), err
+}
@@ -0,0 +1,166 @@
+package util_test
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/winebarrel/terraform-provider-oneshot/internal/util"
+)
+
+func TestWebhookNotify_OK(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	var req *http.Request
+	var body []byte
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req = r
+		body, _ = io.ReadAll(r.Body)
+	}))
+	defer ts.Close()
+
+	webhook, err := util.NewWebhook(ts.URL, map[string]string{"Authorization": "Bearer xxx"}, nil, "", "my-secret", 0, 10*time.Second)
+	require.NoError(err)
+
+	err = webhook.Notify(context.Background(), &util.WebhookPayload{Event: util.EventSuccess, RunID: "run-1", Phase: "apply", Command: "echo hello"})
+	require.NoError(err)
+
+	assert.Equal(http.MethodPost, req.Method)
+	assert.Equal("application/json", req.Header.Get("Content-Type"))
+	assert.Equal("Bearer xxx", req.Header.Get("Authorization"))
+
+	var payload util.WebhookPayload
+	json.Unmarshal(body, &payload)
+	assert.Equal("success", payload.Event)
+	assert.Equal("run-1", payload.RunID)
+	assert.Equal("echo hello", payload.Command)
+
+	mac := hmac.New(sha256.New, []byte("my-secret"))
+	mac.Write(body)
+	assert.Equal("sha256="+hex.EncodeToString(mac.Sum(nil)), req.Header.Get(util.WebhookSignatureHeader))
+}
+
+func TestWebhookNotify_Template(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	var body []byte
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ = io.ReadAll(r.Body)
+	}))
+	defer ts.Close()
+
+	webhook, err := util.NewWebhook(ts.URL, nil, nil, `{"text": {{ json (printf "%s: %s (exit code %d)" .Event .Command .ExitCode) }}}`, "", 0, 10*time.Second)
+	require.NoError(err)
+
+	err = webhook.Notify(context.Background(), &util.WebhookPayload{Event: util.EventFailure, Command: `echo "hi"; exit 1`, ExitCode: 1})
+	require.NoError(err)
+	assert.JSONEq(`{"text": "failure: echo \"hi\"; exit 1 (exit code 1)"}`, string(body))
+}
+
+func TestWebhookNotify_InvalidTemplate(t *testing.T) {
+	assert := assert.New(t)
+
+	webhook, err := util.NewWebhook("http://localhost", nil, nil, `{"text": {{ .Command }}}`, "", 0, 10*time.Second)
+	assert.NoError(err)
+
+	err = webhook.Notify(context.Background(), &util.WebhookPayload{Event: util.EventSuccess, Command: "echo hello"})
+	assert.ErrorContains(err, "payload template does not render valid JSON")
+}
+
+func TestWebhookNotify_Events(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	called := 0
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called++
+	}))
+	defer ts.Close()
+
+	webhook, err := util.NewWebhook(ts.URL, nil, []string{util.EventFailure}, "", "", 0, 10*time.Second)
+	require.NoError(err)
+
+	err = webhook.Notify(context.Background(), &util.WebhookPayload{Event: util.EventSuccess})
+	require.NoError(err)
+	assert.Equal(0, called)
+
+	err = webhook.Notify(context.Background(), &util.WebhookPayload{Event: util.EventFailure})
+	require.NoError(err)
+	assert.Equal(1, called)
+
+	_, err = util.NewWebhook(ts.URL, nil, []string{"start"}, "", "", 0, 10*time.Second)
+	assert.ErrorContains(err, "unknown webhook event: start")
+}
+
+func TestWebhookNotify_Retry(t *testing.T) {
+	assert := assert.New(t)
+
+	called := 0
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called++
+
+		if called < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer ts.Close()
+
+	webhook, _ := util.NewWebhook(ts.URL, nil, nil, "", "", 2, 10*time.Second)
+	webhook.RetryInterval = time.Millisecond
+	err := webhook.Notify(context.Background(), &util.WebhookPayload{Event: util.EventSuccess})
+	assert.NoError(err)
+	assert.Equal(3, called)
+}
+
+func TestWebhookNotify_Err(t *testing.T) {
+	assert := assert.New(t)
+
+	called := 0
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called++
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte("invalid payload"))
+	}))
+	defer ts.Close()
+
+	webhook, _ := util.NewWebhook(ts.URL, nil, nil, "", "", 3, 10*time.Second)
+	webhook.RetryInterval = time.Millisecond
+	err := webhook.Notify(context.Background(), &util.WebhookPayload{Event: util.EventSuccess})
+	assert.ErrorContains(err, "400 Bad Request: invalid payload")
+	assert.Equal(1, called)
+}
+
+func TestWebhookNotify_Timeout(t *testing.T) {
+	assert := assert.New(t)
+
+	done := make(chan struct{})
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-done:
+		case <-time.After(time.Second):
+		}
+	}))
+	defer ts.Close()
+	defer close(done)
+
+	webhook, _ := util.NewWebhook(ts.URL, nil, nil, "", "", 0, 50*time.Millisecond)
+	err := webhook.Notify(context.Background(), &util.WebhookPayload{Event: util.EventSuccess})
+	assert.ErrorContains(err, "Client.Timeout exceeded")
+}
@@ -4,8 +4,10 @@ import (
 	"context"
 	"flag"
 	"log"
+	"os"
 
 	"github.com/hashicorp/terraform-plugin-framework/providerserver"
+	"github.com/winebarrel/terraform-provider-oneshot/internal/cli"
 	"github.com/winebarrel/terraform-provider-oneshot/internal/provider"
 )
 
@@ -14,6 +16,18 @@ var (
 )
 
 func main() {
+	if len(os.Args) > 1 {
+		if cmd, ok := cli.Lookup(os.Args[1]); ok {
+			err := cmd(os.Args[2:], os.Stdout)
+
+			if err != nil {
+				log.Fatal(err.Error())
+			}
+
+			return
+		}
+	}
+
 	var debug bool
 
 	flag.BoolVar(&debug, "debug", false, "set to true to run the provider with support for debuggers like delve")